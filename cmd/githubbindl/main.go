@@ -16,6 +16,12 @@ import (
 	"go.uber.org/zap"
 )
 
+// exit codes of check, any other failure exits with 1
+const (
+	exitUpdatesAvailable = 2
+	exitCheckFailed      = 3
+)
+
 func main() {
 
 	var log logr.Logger
@@ -58,6 +64,13 @@ func main() {
 	}
 
 	err = app.App(ctx, httpClient, &item)
+	// the report of check is already printed, only the exit code is left
+	switch err {
+	case app.ErrUpdatesAvailable:
+		os.Exit(exitUpdatesAvailable)
+	case app.ErrCheckFailed:
+		os.Exit(exitCheckFailed)
+	}
 	if err != nil {
 		log.Error(err, "Unable to download bins")
 		os.Exit(1)
@@ -3,10 +3,9 @@ package app
 import (
 	"archive/tar"
 	"archive/zip"
+	"bufio"
 	"bytes"
-	"compress/gzip"
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,7 +14,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -39,6 +37,11 @@ const commandTimeout = 3
 func App(ctx context.Context, httpClient *http.Client, configItem *config.Items) error {
 	log := logr.FromContext(ctx)
 
+	command := viper.GetString(config.DefaultCommandKey)
+	if command == config.CommandRollback {
+		return Rollback(ctx, configItem, viper.GetString(config.DefaultRollbackCliKey), viper.GetString(config.DefaultRollbackToKey))
+	}
+
 	client := github.NewClient(httpClient)
 
 	// since client is a pointer I can't have a baseURL for each endpoint without allot of logic
@@ -68,23 +71,101 @@ func App(ctx context.Context, httpClient *http.Client, configItem *config.Items)
 		client = github.NewClient(tokenClient)
 	}
 
-	// Create the download folder if needed
-	if err := util.MakeDirectoryIfNotExists(viper.GetString(config.DefaultSaveLocationKey)); err != nil {
+	// Create the download folder if needed, check should never change anything
+	if command != config.CommandCheck {
+		if err := util.MakeDirectoryIfNotExists(binLocation()); err != nil {
+			return err
+		}
+	}
+
+	lockLocation := lockFileLocation()
+	lock, err := readLockFile(lockLocation)
+	if err != nil {
+		return err
+	}
+
+	targetPlatform, err := parsePlatform(viper.GetString(config.DefaultPlatformKey))
+	if err != nil {
+		return err
+	}
+
+	// resolve all templates before anything is downloaded so a broken config fails directly
+	jobs, err := createJobs(configItem, targetPlatform)
+	if err != nil {
 		return err
 	}
 
+	if command == config.CommandInstall {
+		if err := util.MakeDirectoryIfNotExists(backupLocation()); err != nil {
+			return err
+		}
+	}
+
+	// every saveLocation have it's own state file
+	states := make(map[string]*stateFile)
+	for i := range jobs {
+		if command == config.CommandInstall {
+			if err := util.MakeDirectoryIfNotExists(jobs[i].saveLocation); err != nil {
+				return err
+			}
+			if err := util.MakeDirectoryIfNotExists(jobs[i].backupLocation); err != nil {
+				return err
+			}
+		}
+		if _, ok := states[jobs[i].saveLocation]; !ok {
+			states[jobs[i].saveLocation], err = readStateFile(jobs[i].saveLocation)
+			if err != nil {
+				return err
+			}
+		}
+		jobs[i].state = states[jobs[i].saveLocation]
+	}
+
 	var wg sync.WaitGroup
-	channel := make(chan error, len(configItem.Bins))
+	channel := make(chan error, len(jobs))
+	binReport := &report{}
 
-	for i := range configItem.Bins {
-		// TODO check configItem.Bins[i].Download == false and create a report function that only is called.
+	for i := range jobs {
 		wg.Add(1)
-		go downloadBin(ctx, &wg, channel, client, httpClient, configItem.Bins[i])
+		// bins with download: false is only reported
+		if command == config.CommandCheck || !jobs[i].bin.DownloadEnabled() {
+			go reportBin(ctx, &wg, client, httpClient, jobs[i], binReport)
+			continue
+		}
+		go downloadBin(ctx, &wg, channel, client, httpClient, jobs[i], lock)
 	}
 
 	// Blocking, waiting for the wg to finish
 	wg.Wait()
 
+	if len(binReport.Bins) > 0 {
+		if err := binReport.print(os.Stdout, viper.GetString(config.DefaultOutputKey)); err != nil {
+			close(channel)
+			return err
+		}
+	}
+	// the error of check tells if there is anything to update or if any bin couldn't be checked, the details is in the report
+	if command == config.CommandCheck {
+		close(channel)
+		return binReport.result()
+	}
+
+	// frozen never changes the lock file, else save what got installed even if some bins failed
+	if !viper.GetBool(config.DefaultFrozenKey) && command != config.CommandCheck {
+		if err := lock.write(lockLocation, jobs); err != nil {
+			close(channel)
+			return err
+		}
+	}
+	if command == config.CommandInstall {
+		for saveLocation, state := range states {
+			if err := state.write(saveLocation); err != nil {
+				close(channel)
+				return err
+			}
+		}
+	}
+
 	// only check for errors, if no error close the channel and return nil
 	select {
 	case err := <-channel:
@@ -100,112 +181,214 @@ func App(ctx context.Context, httpClient *http.Client, configItem *config.Items)
 
 }
 
-func downloadBin(ctx context.Context, wg *sync.WaitGroup, channel chan error, client *github.Client, httpClient *http.Client, binConfig config.Bin) {
+func downloadBin(ctx context.Context, wg *sync.WaitGroup, channel chan error, client *github.Client, httpClient *http.Client, j job, lock *lockFile) {
 	defer wg.Done()
 
+	err := installBin(ctx, client, httpClient, j, lock)
+	if err != nil {
+		channel <- err
+	}
+}
+
+// installBin resolves what to download, or takes it from the lock file if frozen, downloads and installs it.
+// Nothing is downloaded if the state file shows that the resolved release already is installed.
+// The update command only refreshes the lock file and don't install anything.
+func installBin(ctx context.Context, client *github.Client, httpClient *http.Client, j job, lock *lockFile) error {
 	log := logr.FromContext(ctx)
+	frozen := viper.GetBool(config.DefaultFrozenKey)
+	binConfig := j.bin
+	state := j.state
+
+	var entry lockEntry
+	var err error
+	if frozen {
+		entry, err = lock.frozenEntry(j)
+	} else {
+		entry, err = resolveAsset(ctx, client, httpClient, binConfig, j.platform)
+		entry.Platform = j.lockPlatform()
+	}
+	if err != nil {
+		return err
+	}
 
-	log.Info(binConfig.NonGithubURL)
-	if binConfig.NonGithubURL != "" {
-		// Instead of using httpClient.Timeout I use a ctx with Deadline.
-		ctx, cancel := context.WithDeadline(ctx, time.Now().Add(time.Duration(viper.GetInt(config.DefaultHTTPtimeoutkey))*time.Second))
-		defer cancel()
+	pinned, err := pinnedDigest(binConfig.Cli, "sha256", binConfig.SHA256, j.platform)
+	if err != nil {
+		return err
+	}
+	pinnedBinary, err := pinnedDigest(binConfig.Cli, "binarySha256", binConfig.BinarySHA256, j.platform)
+	if err != nil {
+		return err
+	}
 
-		req, err := http.NewRequest(http.MethodGet, binConfig.NonGithubURL, nil)
-		if err != nil {
-			channel <- err
-			return
-		}
-		req = req.WithContext(ctx)
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			channel <- err
-			return
-		}
-		defer resp.Body.Close()
+	saveLocation := j.saveLocation
+	binaryLocation := filepath.Join(saveLocation, binConfig.InstallName())
+	if installed, ok := state.unchanged(entry, binaryLocation); ok && satisfiesPinned(installed, pinned, pinnedBinary) {
+		log.Info("Already installed, skipping", "cli", binConfig.Cli, "tag", installed.Tag)
+		lock.set(installed.lockEntry)
+		return nil
+	}
 
-		saveLocation := viper.GetString(config.DefaultSaveLocationKey)
-		err = pickExtension(ctx, resp.Body, binConfig.Cli, saveLocation, binConfig.NonGithubURL, binConfig.Backup)
-		if err != nil {
-			channel <- err
-			return
+	asset, err := downloadAsset(ctx, client, httpClient, binConfig, entry)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := asset.remove(); err != nil {
+			log.Info("Unable to remove the downloaded file", "cli", binConfig.Cli, "file", asset.path, "err", err.Error())
 		}
+	}()
 
-		if binConfig.CompletionLocation != "" {
-			err := saveCompletion(ctx, saveLocation, binConfig.Cli, binConfig.CompletionLocation, binConfig.CompletionArgs)
-			if err != nil {
-				channel <- err
-				return
+	checksum := asset.sha256
+	if frozen && (checksum != entry.SHA256 || asset.size != entry.Size) {
+		return fmt.Errorf("%v: %v differs from the lock file, expected sha256 %v and size %v got sha256 %v and size %v", binConfig.Cli, entry.AssetName, entry.SHA256, entry.Size, checksum, asset.size)
+	}
+	if err := checkPinned(binConfig.Cli, entry.AssetName, pinned, checksum); err != nil {
+		return err
+	}
+	// the lock file sha256 was verified when it was written, a pinned sha256 or a signature is enough for requireChecksum
+	if !frozen {
+		if err := verifyDownload(ctx, client, httpClient, binConfig, entry, asset); err != nil {
+			return err
+		}
+		if !signsChecksum(binConfig) {
+			if err := verifyChecksum(ctx, client, httpClient, binConfig, entry, asset, binConfig.ChecksumRequired() && pinned == "" && !signsAsset(binConfig)); err != nil {
+				return err
 			}
 		}
-		// Generate the completion file
-		return
 	}
+	entry.SHA256 = checksum
+	entry.Size = asset.size
 
-	var resp *github.RepositoryRelease
-	var er error
+	// a nonGithubURL can only be compared after it's downloaded
+	if installed, ok := state.unchanged(entry, binaryLocation); ok && satisfiesPinned(installed, pinned, pinnedBinary) {
+		log.Info("Already installed, skipping", "cli", binConfig.Cli, "url", installed.DownloadURL)
+		lock.set(installed.lockEntry)
+		return nil
+	}
 
-	// If tag is empty use GetReleaseByTag
-	if binConfig.Tag != "" {
-		// response gives information about rate limit etc. I assume I will get an error if i go over my rate limit
-		// TODO here a log.debug would be nice...
-		resp, _, er = client.Repositories.GetReleaseByTag(ctx, binConfig.Owner, binConfig.Repo, binConfig.Tag)
-		if er != nil {
-			channel <- er
-			return
+	if viper.GetString(config.DefaultCommandKey) != config.CommandUpdate {
+		// with a installLayout the archive is unpacked in the prefix and the cli ends up in prefix/bin, which is saveLocation
+		dst := saveLocation
+		if j.layoutPrefix != "" {
+			dst = j.layoutPrefix
 		}
-
-	} else {
-		resp, _, er = client.Repositories.GetLatestRelease(ctx, binConfig.Owner, binConfig.Repo)
-		if er != nil {
-			channel <- er
-			return
+		// everything is unpacked in a stage folder first so the installed cli is untouched if anything fails
+		stage, err := ioutil.TempDir(dst, stagePrefix)
+		if err != nil {
+			return err
 		}
-	}
+		defer os.RemoveAll(stage)
 
-	saveLocation := viper.GetString(config.DefaultSaveLocationKey)
-	for _, asset := range resp.Assets {
-		log.Info(*asset.Name)
-		lowerAssetName := strings.ToLower(*asset.Name)
-		patternMatched, err := regexp.MatchString(strings.ToLower(binConfig.Match), lowerAssetName)
+		f, err := os.Open(asset.path)
 		if err != nil {
-			channel <- err
-			return
+			return err
+		}
+		err = pickExtension(ctx, f, binConfig, stage, strings.ToLower(entry.AssetName), asset.header, j.layoutPrefix != "")
+		_ = f.Close()
+		if err != nil {
+			return err
 		}
-		if patternMatched {
-			rc, _, err := client.Repositories.DownloadReleaseAsset(ctx, binConfig.Owner, binConfig.Repo, *asset.ID, httpClient)
+		if pinnedBinary != "" {
+			rel, err := filepath.Rel(dst, binaryLocation)
 			if err != nil {
-				channel <- err
-				return
+				return err
 			}
-			err = pickExtension(ctx, rc, binConfig.Cli, saveLocation, lowerAssetName, binConfig.Backup)
+			stagedChecksum, err := util.FileSHA256(filepath.Join(stage, rel))
 			if err != nil {
-				channel <- err
-				return
+				return err
 			}
+			if err := checkPinned(binConfig.Cli, "the unpacked "+binConfig.InstallName(), pinnedBinary, stagedChecksum); err != nil {
+				return err
+			}
+		}
 
-			// Generate the completion file
-			if binConfig.CompletionLocation != "" {
-				err := saveCompletion(ctx, saveLocation, binConfig.Cli, binConfig.CompletionLocation, binConfig.CompletionArgs)
-				if err != nil {
-					channel <- err
-					return
-				}
+		backupLocation := j.backupLocation
+		if binConfig.Backup {
+			// the backup is named after the version that is installed right now
+			installed, _ := state.get(binConfig.InstallName())
+			err := copyOldCli(binConfig.InstallName(), saveLocation, backupLocation, installed.Tag)
+			if err != nil {
+				// The application will continue and instead overwrite the existing cliName
+				log.Info("Unable to save a old version of cli", "cli", binConfig.Cli, "err", err.Error())
 			}
+		}
 
-			return
+		if err := moveStaged(stage, dst); err != nil {
+			return err
 		}
+
+		if binConfig.Backup && binConfig.BackupRetention != nil {
+			if err := pruneBackups(ctx, binConfig.InstallName(), backupLocation, *binConfig.BackupRetention); err != nil {
+				log.Info("Unable to prune old backups", "cli", binConfig.Cli, "err", err.Error())
+			}
+		}
+
+		// Generate the completion file
+		if binConfig.CompletionLocation != "" {
+			err := saveCompletion(ctx, saveLocation, binConfig.InstallName(), binConfig.CompletionLocation, binConfig.CompletionArgs)
+			if err != nil {
+				return err
+			}
+		}
+
+		binaryChecksum, err := util.FileSHA256(binaryLocation)
+		if err != nil {
+			return err
+		}
+		state.set(stateEntry{lockEntry: entry, BinarySHA256: binaryChecksum, InstalledAt: time.Now()})
+	}
+
+	log.Info("Resolved", "cli", binConfig.Cli, "tag", entry.Tag, "asset", entry.AssetName, "sha256", entry.SHA256)
+	lock.set(entry)
+	return nil
+}
+
+// fetchAsset downloads a small release file like a checksum file or a signature in to memory, the asset itself is streamed to disk by downloadAsset.
+// Release assets is downloaded through the GitHub API so private repos works.
+// The response headers is only returned for nonGithubURL downloads.
+func fetchAsset(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, entry lockEntry) ([]byte, http.Header, error) {
+	if entry.AssetID != 0 {
+		rc, _, err := client.Repositories.DownloadReleaseAsset(ctx, binConfig.Owner, binConfig.Repo, entry.AssetID, httpClient)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer rc.Close()
+		data, err := readLimited(rc, binConfig.Cli)
+		return data, nil, err
 	}
+	return fetchURL(ctx, httpClient, binConfig.Cli, entry.DownloadURL)
+}
+
+// fetchURL downloads the url in to memory, anything but a 2xx status is an error
+func fetchURL(ctx context.Context, httpClient *http.Client, cliName, downloadURL string) ([]byte, http.Header, error) {
+	resp, cancel, err := getURL(ctx, httpClient, cliName, downloadURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cancel()
+	defer resp.Body.Close()
+
+	data, err := readLimited(resp.Body, cliName)
+	return data, resp.Header, err
+}
 
-	// normally return earlier, should only come here if we fail to find the bin
-	channel <- errors.New("Unable to find match")
+// readLimited reads everything in to memory, but never more than maxDownloadSize
+func readLimited(r io.Reader, cliName string) ([]byte, error) {
+	maxDownloadSize := viper.GetInt64(config.DefaultMaxDownloadSizeKey)
+	return ioutil.ReadAll(&maxSizeReader{r: r, name: cliName, setting: config.DefaultMaxDownloadSizeKey, left: maxDownloadSize, max: maxDownloadSize})
 }
 
-// copyOldCli copies the current cli to the same location but with addition of _2006-01-02
-func copyOldCli(cliName, saveLocation string) error {
+// copyOldCli copies the current cli to backupLocation as <cli>_<version>.
+// If the version is unknown or already have a backup the time is used instead, example: tkn_2006-01-02_150405
+func copyOldCli(cliName, saveLocation, backupLocation, version string) error {
 	target := filepath.Join(saveLocation, cliName)
 
-	dst := target + "_" + time.Now().Local().Format(util.DateFormat)
+	now := time.Now().Local().Format(util.DateTimeFormat)
+	suffix := backupVersion(version)
+	if suffix == "" {
+		suffix = now
+	}
+	dst := filepath.Join(backupLocation, cliName+"_"+suffix)
 
 	srcStat, err := os.Stat(target)
 	if err != nil {
@@ -225,11 +408,8 @@ func copyOldCli(cliName, saveLocation string) error {
 		if !(dstStat.Mode().IsRegular()) {
 			return fmt.Errorf("CopyFile: non-regular destination file %s (%q)", dstStat.Name(), dstStat.Mode().String())
 		}
-		// I don't understand how SameFile works, even though the files are copies of eachother they still return false.
-		// Well in some special case it could speed it up by not having to copy the data.
-		if os.SameFile(srcStat, dstStat) {
-			return nil
-		}
+		// never overwrite a older backup of the same version, it might be the only working copy
+		dst += "_" + now
 	}
 	err = copyFileContents(target, dst, srcStat.Mode())
 	if err != nil {
@@ -279,51 +459,93 @@ func copyFileContents(target, dst string, srcStat os.FileMode) (err error) {
 	return nil
 }
 
-func pickExtension(ctx context.Context, respBody io.ReadCloser, cliName, saveLocation, downloadURL string, backup bool) error {
+// pickExtension installs the download based on its format, see detectFormat.
+// With layout saveLocation is the installLayout prefix and the cli is installed in saveLocation/bin.
+func pickExtension(ctx context.Context, respBody io.ReadCloser, binConfig config.Bin, saveLocation, assetName string, header http.Header, layout bool) error {
+	rules, err := extractRules(binConfig, layout)
+	if err != nil {
+		return err
+	}
+	// the first rule is always the cli
+	binDir := filepath.Join(saveLocation, rules[0].dir)
 
-	log := logr.FromContext(ctx)
+	body := bufio.NewReaderSize(respBody, sniffLength)
+	// a short download gives a error from Peek, the bytes that was read is still returned
+	head, _ := body.Peek(sniffLength)
+	format, err := detectFormat(binConfig.Format, head, header, assetName)
+	if err != nil {
+		return fmt.Errorf("%v: %v", binConfig.Cli, err)
+	}
 
-	if backup {
-		err := copyOldCli(cliName, saveLocation)
-		if err != nil {
-			// The application will continue and instead overwrite the existing cliName
-			log.Info("msg", "Unable to save a old version of cli ", err)
-		}
+	if compression, ok := compressions[format]; ok {
+		return unpackCompressed(ctx, body, binConfig, saveLocation, binDir, rules, format, compression)
 	}
-	switch filepath.Ext(downloadURL) {
-	case gzExtension:
-		err := untarGZ(ctx, saveLocation, cliName, respBody)
+
+	switch format {
+	case formatTar:
+		err := untar(ctx, saveLocation, rules, body)
 		if err != nil {
 			return err
 		}
 		return nil
-	case zipExtension:
-		err := unZIP(ctx, saveLocation, cliName, respBody)
+	case formatZIP:
+		// zip reads the index at the end of the file, a download on disk is read in place
+		var zr io.Reader = body
+		if f, ok := respBody.(*os.File); ok {
+			zr = f
+		}
+		err := unZIP(ctx, saveLocation, rules, zr)
 		if err != nil {
 			return err
 		}
 		return nil
-	case "", exeExtension:
-		err := saveFile(ctx, saveLocation, cliName, respBody)
+	case formatDeb:
+		if err := undeb(ctx, saveLocation, rules, body); err != nil {
+			return fmt.Errorf("%v: %v", binConfig.Cli, err)
+		}
+		return nil
+	case formatRPM:
+		if err := unrpm(ctx, saveLocation, rules, body); err != nil {
+			return fmt.Errorf("%v: %v", binConfig.Cli, err)
+		}
+		return nil
+	case formatBinary:
+		err := saveFile(ctx, binDir, binConfig.InstallName(), body)
 		if err != nil {
 			return err
 		}
 	default:
-		return errors.New("The file extenssion is not supported")
+		return fmt.Errorf("%v: the format %v is not supported", binConfig.Cli, format)
 	}
 
 	return nil
 }
 
-//saveFile used if the file have no extension
-func saveFile(ctx context.Context, dst, cliName string, rc io.Reader) error {
-	log := logr.FromContext(ctx)
-	buf := new(bytes.Buffer)
-	_, err := buf.ReadFrom(rc)
+// unpackCompressed decompresses the download, a tar is unpacked and anything else is saved as the cli
+func unpackCompressed(ctx context.Context, body io.Reader, binConfig config.Bin, saveLocation, binDir string, rules []*extractRule, format, compression string) error {
+	dr, err := decompress(compression, body)
 	if err != nil {
-		return err
+		return fmt.Errorf("%v: %v", binConfig.Cli, err)
+	}
+	defer dr.Close()
+
+	inner := bufio.NewReaderSize(dr, sniffLength)
+	head, _ := inner.Peek(sniffLength)
+	if format != compression || isTarHeader(head) {
+		return untar(ctx, saveLocation, rules, inner)
 	}
 
+	if len(binConfig.Files) > 0 {
+		return fmt.Errorf("%v: files can only be used with archives, the download is a single %v compressed file", binConfig.Cli, compression)
+	}
+	maxFileSize := viper.GetInt64(config.DefaultMaxFileSizeKey)
+	return saveFile(ctx, binDir, binConfig.InstallName(), &maxSizeReader{r: inner, name: binConfig.Cli, setting: config.DefaultMaxFileSizeKey, left: maxFileSize, max: maxFileSize})
+}
+
+// saveFile used if the file have no extension
+func saveFile(ctx context.Context, dst, cliName string, rc io.Reader) error {
+	log := logr.FromContext(ctx)
+
 	target := filepath.Join(dst, cliName)
 	if !strings.HasPrefix(target, filepath.Clean(dst)+string(os.PathSeparator)) {
 		return fmt.Errorf("%s: illegal file path", target)
@@ -334,8 +556,9 @@ func saveFile(ctx context.Context, dst, cliName string, rc io.Reader) error {
 	}
 
 	log.Info("Downloading", "target", target)
-	_, err = buf.WriteTo(f)
+	_, err = io.Copy(f, rc)
 	if err != nil {
+		_ = f.Close()
 		return err
 	}
 	//CLOSE THE FILE
@@ -394,26 +617,20 @@ func saveCompletion(ctx context.Context, cliLocation, cliName, completionLocatio
 	return nil
 }
 
-// untarGZ tar.gz files and put the result in any folder you want
-func untarGZ(ctx context.Context, dst, cliName string, r io.Reader) error {
+// untar unpacks a uncompressed tar and put the files matching the rules in any folder you want
+func untar(ctx context.Context, dst string, rules []*extractRule, r io.Reader) error {
 	log := logr.FromContext(ctx)
 
-	gzr, err := gzip.NewReader(r)
-	if err != nil {
-		return err
-	}
-	defer gzr.Close()
-
-	tr := tar.NewReader(gzr)
+	tr := tar.NewReader(r)
 
 	for {
 		header, err := tr.Next()
 
 		switch {
 
-		// if no more files are found return
+		// if no more files are found check that all rules found something
 		case err == io.EOF:
-			return nil
+			return checkRules(rules)
 
 		// return any other error
 		case err != nil:
@@ -424,13 +641,6 @@ func untarGZ(ctx context.Context, dst, cliName string, r io.Reader) error {
 			continue
 		}
 
-		/* HELM is a pain, the bin file is inside a folder.
-		First split the target and now join it with the base dir where we want to save the file.
-		*/
-		// the target location + header.Name - it's sub folder
-		_, cleanHeader := filepath.Split(header.Name)
-		target := filepath.Join(dst, cleanHeader)
-
 		// check the file type
 		switch header.Typeflag {
 
@@ -445,55 +655,49 @@ func untarGZ(ctx context.Context, dst, cliName string, r io.Reader) error {
 
 		// if it's a file create it
 		case tar.TypeReg:
-			// Only write the cliFile
-			if cleanHeader == cliName {
-				if !strings.HasPrefix(target, filepath.Clean(dst)+string(os.PathSeparator)) {
-					return fmt.Errorf("%s: illegal file path", target)
-				}
-
-				maxFileSize := viper.GetInt64(config.DefaultMaxFileSizeKey)
-				// Fix G110 max size of a unpacked file, still don't take memory in to consideration but it shouldn't fil your disk to much
-				//gitHubAPIkey := viper.GetString(config.DefaultGITHUBAPIKEYKey)
-				if header.Size > maxFileSize {
-					return fmt.Errorf("%v: is %v which is bigger than allowed maxFileSize %v byte", cleanHeader, header.Size, maxFileSize)
-				}
-				// TODO change to some debug...
-				log.Info(cleanHeader)
-				/* Since I only untar the cli it self I enforce 0755
-				   else use os.FileMode(header.Mode) to get what the filed had when it was tared.
-				*/
-				file, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(0755)) // #nosec G304
-				if err != nil {
-					return err
-				}
+			memberPath := cleanMemberPath(header.Name)
+			rule := matchRule(rules, memberPath)
+			if rule == nil {
+				continue
+			}
+			rule.found++
 
-				// copy over contents
-				/* #nosec G110*/
-				if _, err := io.Copy(file, tr); err != nil {
-					return err
-				}
+			maxFileSize := viper.GetInt64(config.DefaultMaxFileSizeKey)
+			// Fix G110 max size of a unpacked file, still don't take memory in to consideration but it shouldn't fil your disk to much
+			if header.Size > maxFileSize {
+				return fmt.Errorf("%v: is %v which is bigger than allowed maxFileSize %v byte", memberPath, header.Size, maxFileSize)
+			}
+			// TODO change to some debug...
+			log.Info(memberPath)
 
-				// manually close here after each file operation; defering would cause each file close
-				// to wait until all operations have completed.
-				err = file.Close()
-				if err != nil {
-					return err
-				}
+			if err := writeFile(dst, rule.target(dst, memberPath), rule.mode, tr); err != nil {
+				return err
 			}
 		}
 	}
 }
 
-// unZIP unzip files and put the result in any folder you want
-func unZIP(ctx context.Context, dst, cliName string, respBody io.Reader) error {
+// unZIP unzip files and put the files matching the rules in any folder you want.
+// A file is read in place, anything else is spooled to a temp file first since zip needs to read the end of the file.
+func unZIP(ctx context.Context, dst string, rules []*extractRule, respBody io.Reader) error {
 	log := logr.FromContext(ctx)
 
-	zipRespBody, err := ioutil.ReadAll(respBody)
+	f, ok := respBody.(*os.File)
+	if !ok {
+		var err error
+		f, _, err = spool(respBody, "zip archive")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+	}
+	info, err := f.Stat()
 	if err != nil {
 		return err
 	}
 
-	zipReader, err := zip.NewReader(bytes.NewReader(zipRespBody), int64(len(zipRespBody)))
+	zipReader, err := zip.NewReader(f, info.Size())
 	if err != nil {
 		return err
 	}
@@ -501,53 +705,39 @@ func unZIP(ctx context.Context, dst, cliName string, respBody io.Reader) error {
 	log.Info("we are in zip")
 
 	for _, f := range zipReader.File {
-		// the target location + header.Name - it's sub folder
-		_, cleanHeader := filepath.Split(f.Name)
-		target := filepath.Join(dst, cleanHeader)
-
-		if cleanHeader == cliName {
-			// Check for ZipSlip. More Info: http://bit.ly/2MsjAWE
-			if !strings.HasPrefix(target, filepath.Clean(dst)+string(os.PathSeparator)) {
-				return fmt.Errorf("%s: illegal file path", target)
-			}
-
-			// using Uint instead of int
-			maxFileSize := viper.GetUint64(config.DefaultMaxFileSizeKey)
-			// Fix G110 max size of a unpacked file, still don't take memory in to consideration but it shouldn't fil your disk to much
-			if f.UncompressedSize64 > maxFileSize {
-				return fmt.Errorf("%v: is %v which is bigger than allowed maxFileSize %v byte", cleanHeader, f.UncompressedSize64, maxFileSize)
-			}
-
-			outFile, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode()) // #nosec G304
-			if err != nil {
-				return err
-			}
-
-			rc, err := f.Open()
-			if err != nil {
-				return err
-			}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		memberPath := cleanMemberPath(f.Name)
+		rule := matchRule(rules, memberPath)
+		if rule == nil {
+			continue
+		}
+		rule.found++
 
-			_, err = io.Copy(outFile, rc) // #nosec G110
-			if err != nil {
-				return err
-			}
+		// using Uint instead of int
+		maxFileSize := viper.GetUint64(config.DefaultMaxFileSizeKey)
+		// Fix G110 max size of a unpacked file, still don't take memory in to consideration but it shouldn't fil your disk to much
+		if f.UncompressedSize64 > maxFileSize {
+			return fmt.Errorf("%v: is %v which is bigger than allowed maxFileSize %v byte", memberPath, f.UncompressedSize64, maxFileSize)
+		}
 
-			// Close the file without defer to close before next iteration of loop
-			err = outFile.Close()
-			if err != nil {
-				return err
-			}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
 
-			err = rc.Close()
-			if err != nil {
-				return err
-			}
+		err = writeFile(dst, rule.target(dst, memberPath), rule.mode, rc)
+		if err != nil {
+			_ = rc.Close()
+			return err
+		}
 
-			if err != nil {
-				return err
-			}
+		// Close the file without defer to close before next iteration of loop
+		err = rc.Close()
+		if err != nil {
+			return err
 		}
 	}
-	return nil
+	return checkRules(rules)
 }
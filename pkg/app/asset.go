@@ -0,0 +1,104 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/google/go-github/v33/github"
+)
+
+// sidecarSuffixes checksum, signature and SBOM files that is released next to the real assets, they are never installed.
+// A suffix only makes a sidecar if it follows the name of another asset in the release, example: tool.tar.gz.sig or tool.sbom.json next to tool.tar.gz
+var sidecarSuffixes = []string{
+	".sha256", ".sha256sum", ".sha512", ".sha512sum", ".sha1", ".md5",
+	".sig", ".asc", ".pem", ".crt", ".cert", ".pub", ".minisig",
+	".sbom", ".sbom.json", ".spdx", ".spdx.json", ".cdx.json", ".bom.json",
+	".intoto.jsonl", ".sigstore.json", ".sigstore", ".bundle", ".att",
+}
+
+// sidecarNames checksum files that don't belong to a single asset, the name have to end with it like checksums.txt or tool_1.0.0_SHA256SUMS
+var sidecarNames = regexp.MustCompile(`^(?:.+[._-])?(?:checksums?|sha256sums?|sha512sums?|shasums?)(?:\.txt)?$`)
+
+// releaseNames returns the lower case names of all assets in the release, also without the archive extension
+func releaseNames(release *github.RepositoryRelease) map[string]bool {
+	names := make(map[string]bool)
+	for _, asset := range release.Assets {
+		name := strings.ToLower(asset.GetName())
+		names[name] = true
+		for _, s := range nameSuffixes {
+			if strings.HasSuffix(name, s.suffix) {
+				names[strings.TrimSuffix(name, s.suffix)] = true
+				break
+			}
+		}
+	}
+	return names
+}
+
+// isSidecar returns true for checksum files of the whole release and checksum, signature and SBOM files of another asset in the release
+func isSidecar(assetName string, names map[string]bool) bool {
+	lowerAssetName := strings.ToLower(assetName)
+	if sidecarNames.MatchString(lowerAssetName) {
+		return true
+	}
+	for _, suffix := range sidecarSuffixes {
+		if strings.HasSuffix(lowerAssetName, suffix) && names[strings.TrimSuffix(lowerAssetName, suffix)] {
+			return true
+		}
+	}
+	return false
+}
+
+// selectAsset returns the only asset that matches the bin, without match the asset is picked by autoSelectAsset.
+// Sidecar files and assets matching any of the exclude patterns are skipped, if more than one asset is left it's an error since the pick would depend on the asset order.
+func selectAsset(binConfig config.Bin, release *github.RepositoryRelease, p platform) (*github.ReleaseAsset, error) {
+	var exclude []*regexp.Regexp
+	for _, pattern := range binConfig.Exclude {
+		r, err := regexp.Compile(strings.ToLower(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("%v: invalid exclude pattern %q: %v", binConfig.Cli, pattern, err)
+		}
+		exclude = append(exclude, r)
+	}
+
+	releaseAssets := releaseNames(release)
+	var assets []*github.ReleaseAsset
+	for _, asset := range release.Assets {
+		lowerAssetName := strings.ToLower(asset.GetName())
+		if isSidecar(lowerAssetName, releaseAssets) || matchesAny(lowerAssetName, exclude) {
+			continue
+		}
+		assets = append(assets, asset)
+	}
+
+	if binConfig.Match == "" {
+		return autoSelectAsset(binConfig, release.GetTagName(), assets, p)
+	}
+
+	match, err := regexp.Compile(strings.ToLower(binConfig.Match))
+	if err != nil {
+		return nil, fmt.Errorf("%v: invalid match pattern %q: %v", binConfig.Cli, binConfig.Match, err)
+	}
+
+	var candidates []*github.ReleaseAsset
+	for _, asset := range assets {
+		if match.MatchString(strings.ToLower(asset.GetName())) {
+			candidates = append(candidates, asset)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("%v: unable to find a asset matching %q in release %v", binConfig.Cli, binConfig.Match, release.GetTagName())
+	case 1:
+		return candidates[0], nil
+	}
+
+	names := make([]string, len(candidates))
+	for i, asset := range candidates {
+		names[i] = asset.GetName()
+	}
+	return nil, fmt.Errorf("%v: %v assets in release %v matches %q, make match more specific or use exclude: %v", binConfig.Cli, len(candidates), release.GetTagName(), binConfig.Match, strings.Join(names, ", "))
+}
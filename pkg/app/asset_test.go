@@ -0,0 +1,91 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/google/go-github/v33/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectAsset(t *testing.T) {
+	release := &github.RepositoryRelease{TagName: github.String("v0.15.0")}
+	for _, name := range []string{
+		"tool_linux_amd64.tar.gz.sha256",
+		"tool_linux_amd64.tar.gz",
+		"tool_linux_amd64.tar.gz.sig",
+		"tool_linux_amd64.sbom.json",
+		"tool_linux_amd64.intoto.jsonl",
+		"tool_0.15.0_checksums.txt",
+		"tool_linux_amd64_debug.tar.gz",
+		"tool_darwin_amd64.tar.gz",
+		"tool_darwin_amd64.zip",
+	} {
+		release.Assets = append(release.Assets, &github.ReleaseAsset{Name: github.String(name)})
+	}
+
+	tests := []struct {
+		match     string
+		exclude   []string
+		expectOut string
+		expectErr bool
+	}{
+		{match: "linux_amd64.tar.gz$", expectOut: "tool_linux_amd64.tar.gz"},
+		{match: "linux_amd64", exclude: []string{"_debug"}, expectOut: "tool_linux_amd64.tar.gz"},
+		{match: "Linux_AMD64", exclude: []string{"DEBUG"}, expectOut: "tool_linux_amd64.tar.gz"},
+		{match: "linux_amd64", expectErr: true},
+		{match: "darwin_amd64", expectErr: true},
+		{match: "windows", expectErr: true},
+		{match: "(", expectErr: true},
+		{match: "linux", exclude: []string{"("}, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		asset, err := selectAsset(config.Bin{Cli: "tool", Match: tests.match, Exclude: tests.exclude}, release, hostPlatform())
+		if tests.expectErr {
+			assert.Error(t, err, tests.match)
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unable to select asset for %v, err: %v", tests.match, err)
+			continue
+		}
+		assert.Equal(t, tests.expectOut, asset.GetName(), tests.match)
+	}
+
+	// the error lists all candidates
+	_, err := selectAsset(config.Bin{Cli: "tool", Match: "darwin"}, release, hostPlatform())
+	assert.EqualError(t, err, `tool: 2 assets in release v0.15.0 matches "darwin", make match more specific or use exclude: tool_darwin_amd64.tar.gz, tool_darwin_amd64.zip`)
+}
+
+// only whole checksum file names and suffixes after another asset in the release is a sidecar
+func TestIsSidecar(t *testing.T) {
+	release := &github.RepositoryRelease{}
+	for _, name := range []string{"tool_linux_amd64.tar.gz", "tool_darwin_amd64.zip", "checksums.txt"} {
+		release.Assets = append(release.Assets, &github.ReleaseAsset{Name: github.String(name)})
+	}
+	names := releaseNames(release)
+
+	tests := []struct {
+		name      string
+		expectOut bool
+	}{
+		{name: "tool_linux_amd64.tar.gz.sha256", expectOut: true},
+		{name: "tool_linux_amd64.tar.gz.sig", expectOut: true},
+		{name: "tool_linux_amd64.sbom.json", expectOut: true},
+		{name: "tool_darwin_amd64.zip.bundle", expectOut: true},
+		{name: "checksums.txt", expectOut: true},
+		{name: "checksums.txt.sig", expectOut: true},
+		{name: "tool_0.15.0_SHA256SUMS", expectOut: true},
+		{name: "checksum-tool_linux_amd64.tar.gz", expectOut: false},
+		{name: "shasum-helper_linux_amd64.tar.gz", expectOut: false},
+		{name: "tool_linux_amd64.tar.gz", expectOut: false},
+		{name: "release.pub", expectOut: false},
+		{name: "tool.bundle", expectOut: false},
+		{name: "tool_windows_amd64.crt", expectOut: false},
+	}
+
+	for _, tests := range tests {
+		assert.Equal(t, tests.expectOut, isSidecar(tests.name, names), tests.name)
+	}
+}
@@ -0,0 +1,217 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/google/go-github/v33/github"
+	"github.com/spf13/viper"
+)
+
+// scores used by autoSelectAsset, the os must always match
+const (
+	scoreOS            = 10
+	scoreArch          = 5
+	scoreUniversalArch = 3
+	scoreStaticLibc    = 2
+	scoreGNULibc       = 1
+)
+
+// formatScores the formats that can be installed, archives is preferred since they often contain more than the cli
+var formatScores = map[string]int{
+	formatTarGZ:    3,
+	formatTarXZ:    3,
+	formatTarBZIP2: 3,
+	formatTarZSTD:  3,
+	formatTar:      3,
+	formatZIP:      2,
+	formatGZ:       1,
+	formatXZ:       1,
+	formatBZIP2:    1,
+	formatZSTD:     1,
+	formatBinary:   1,
+	// packages is only used if nothing else is released for the platform
+	formatDeb: 0,
+	formatRPM: 0,
+}
+
+// universalArchTokens builds that works on all architectures, normally darwin
+var universalArchTokens = []string{"universal", "all"}
+
+// assetScore how well a asset fits the platform, assets with a reason to skip them is never picked
+type assetScore struct {
+	name    string
+	asset   *github.ReleaseAsset
+	score   int
+	reasons []string
+	skip    string
+}
+
+// tokenMatcher matches names that contains any of the tokens surrounded by non alphanumeric characters, a matcher without tokens never matches
+type tokenMatcher struct {
+	r *regexp.Regexp
+}
+
+func newTokenMatcher(tokens []string) tokenMatcher {
+	if len(tokens) == 0 {
+		return tokenMatcher{}
+	}
+	quoted := make([]string, len(tokens))
+	for i, token := range tokens {
+		quoted[i] = regexp.QuoteMeta(strings.ToLower(token))
+	}
+	return tokenMatcher{r: regexp.MustCompile(`(^|[^a-z0-9])(?:` + strings.Join(quoted, "|") + `)([^a-z0-9]|$)`)}
+}
+
+func (m tokenMatcher) match(name string) bool {
+	return m.r != nil && m.r.MatchString(name)
+}
+
+// otherTokens returns the aliases of every other value than the target
+func otherTokens(target string, builtIn map[string][]string) []string {
+	var tokens []string
+	for value, list := range builtIn {
+		if value != target {
+			tokens = append(tokens, list...)
+		}
+	}
+	return tokens
+}
+
+// assetMatcher the token matchers for a bin and platform, they are compiled once and used for every asset
+type assetMatcher struct {
+	platform  platform
+	os        tokenMatcher
+	otherOS   tokenMatcher
+	arch      tokenMatcher
+	otherArch tokenMatcher
+	universal tokenMatcher
+	static    tokenMatcher
+	gnu       tokenMatcher
+}
+
+func newAssetMatcher(binConfig config.Bin, p platform) *assetMatcher {
+	return &assetMatcher{
+		platform:  p,
+		os:        newTokenMatcher(aliases(p.os, osAliases, binConfig.OSAliases)),
+		otherOS:   newTokenMatcher(otherTokens(p.os, osAliases)),
+		arch:      newTokenMatcher(aliases(p.arch, archAliases, binConfig.ArchAliases)),
+		otherArch: newTokenMatcher(otherTokens(p.arch, archAliases)),
+		universal: newTokenMatcher(universalArchTokens),
+		static:    newTokenMatcher([]string{"musl", "static"}),
+		gnu:       newTokenMatcher([]string{"gnu", "glibc"}),
+	}
+}
+
+// scoreAsset scores the asset name by os, arch, libc and archive type for the platform
+func scoreAsset(m *assetMatcher, asset *github.ReleaseAsset) assetScore {
+	p := m.platform
+	name := strings.ToLower(asset.GetName())
+	s := assetScore{name: asset.GetName(), asset: asset}
+
+	format, _ := formatFromName(name)
+	formatScore, ok := formatScores[format]
+	if !ok || (strings.HasSuffix(name, exeExtension) && p.os != "windows") {
+		s.skip = fmt.Sprintf("unsupported file type %q", filepath.Ext(name))
+		return s
+	}
+	s.score += formatScore
+	s.reasons = append(s.reasons, fmt.Sprintf("%v +%v", format, formatScore))
+
+	switch {
+	case m.os.match(name):
+		s.score += scoreOS
+		s.reasons = append(s.reasons, fmt.Sprintf("os %v +%v", p.os, scoreOS))
+	case m.otherOS.match(name):
+		s.skip = "built for another os"
+		return s
+	default:
+		s.skip = "no os in the name"
+		return s
+	}
+
+	switch {
+	case m.arch.match(name):
+		s.score += scoreArch
+		s.reasons = append(s.reasons, fmt.Sprintf("arch %v +%v", p.arch, scoreArch))
+	case m.otherArch.match(name):
+		s.skip = "built for another arch"
+		return s
+	case m.universal.match(name):
+		s.score += scoreUniversalArch
+		s.reasons = append(s.reasons, fmt.Sprintf("universal arch +%v", scoreUniversalArch))
+	}
+
+	// a static or musl build runs on every linux, a gnu build needs a new enough glibc
+	if p.os == "linux" {
+		switch {
+		case m.static.match(name):
+			s.score += scoreStaticLibc
+			s.reasons = append(s.reasons, fmt.Sprintf("static libc +%v", scoreStaticLibc))
+		case m.gnu.match(name):
+			s.score += scoreGNULibc
+			s.reasons = append(s.reasons, fmt.Sprintf("gnu libc +%v", scoreGNULibc))
+		}
+	}
+	return s
+}
+
+// autoSelectAsset is used for bins without match, every asset is scored for the platform and the best one is used.
+// Two assets with the same top score is an error, set match or exclude to pick one of them.
+func autoSelectAsset(binConfig config.Bin, tag string, assets []*github.ReleaseAsset, p platform) (*github.ReleaseAsset, error) {
+	m := newAssetMatcher(binConfig, p)
+	scores := make([]assetScore, len(assets))
+	for i, asset := range assets {
+		scores[i] = scoreAsset(m, asset)
+	}
+	// skipped assets last, then the highest score
+	sort.SliceStable(scores, func(i, j int) bool {
+		if (scores[i].skip == "") != (scores[j].skip == "") {
+			return scores[i].skip == ""
+		}
+		return scores[i].score > scores[j].score
+	})
+
+	if viper.GetBool(config.DefaultExplainKey) {
+		printScores(binConfig, tag, p, scores)
+	}
+
+	if len(scores) == 0 || scores[0].skip != "" {
+		return nil, fmt.Errorf("%v: unable to find a asset for %v in release %v, set match to pick one", binConfig.Cli, p, tag)
+	}
+
+	tied := []string{scores[0].name}
+	for _, s := range scores[1:] {
+		if s.skip == "" && s.score == scores[0].score {
+			tied = append(tied, s.name)
+		}
+	}
+	if len(tied) > 1 {
+		return nil, fmt.Errorf("%v: %v assets in release %v have the same score for %v, set match or exclude to pick one: %v", binConfig.Cli, len(tied), tag, p, strings.Join(tied, ", "))
+	}
+	return scores[0].asset, nil
+}
+
+// printScores prints the scores of all assets, the table is written at once since bins are resolved in parallel
+func printScores(binConfig config.Bin, tag string, p platform, scores []assetScore) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Asset scores for %v %v on %v:\n", binConfig.Cli, tag, p)
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ASSET\tSCORE\tREASON")
+	for _, s := range scores {
+		if s.skip != "" {
+			fmt.Fprintf(tw, "%v\t-\tskipped, %v\n", s.name, s.skip)
+			continue
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", s.name, s.score, strings.Join(s.reasons, ", "))
+	}
+	tw.Flush()
+	os.Stdout.Write(buf.Bytes())
+}
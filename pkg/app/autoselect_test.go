@@ -0,0 +1,104 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/google/go-github/v33/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoSelectAsset(t *testing.T) {
+	newRelease := func(names ...string) *github.RepositoryRelease {
+		release := &github.RepositoryRelease{TagName: github.String("v1.0.0")}
+		for _, name := range names {
+			release.Assets = append(release.Assets, &github.ReleaseAsset{Name: github.String(name)})
+		}
+		return release
+	}
+
+	ripgrep := newRelease(
+		"ripgrep-1.0.0-x86_64-unknown-linux-musl.tar.gz",
+		"ripgrep-1.0.0-x86_64-unknown-linux-musl.tar.gz.sha256",
+		"ripgrep-1.0.0-x86_64-unknown-linux-gnu.tar.gz",
+		"ripgrep-1.0.0-aarch64-unknown-linux-gnu.tar.gz",
+		"ripgrep-1.0.0-x86_64-apple-darwin.tar.gz",
+		"ripgrep-1.0.0-x86_64-pc-windows-msvc.zip",
+		"ripgrep_1.0.0_amd64.deb",
+	)
+	tkn := newRelease(
+		"tkn_1.0.0_Linux_x86_64.tar.gz",
+		"tkn_1.0.0_Linux_arm64.tar.gz",
+		"tkn_1.0.0_Darwin_all.tar.gz",
+		"tkn_1.0.0_Windows_x86_64.zip",
+		"checksums.txt",
+	)
+
+	tests := []struct {
+		release   *github.RepositoryRelease
+		platform  platform
+		exclude   []string
+		expectOut string
+		expectErr bool
+	}{
+		{release: ripgrep, platform: platform{os: "linux", arch: "amd64"}, expectOut: "ripgrep-1.0.0-x86_64-unknown-linux-musl.tar.gz"},
+		{release: ripgrep, platform: platform{os: "linux", arch: "arm64"}, expectOut: "ripgrep-1.0.0-aarch64-unknown-linux-gnu.tar.gz"},
+		{release: ripgrep, platform: platform{os: "darwin", arch: "amd64"}, expectOut: "ripgrep-1.0.0-x86_64-apple-darwin.tar.gz"},
+		{release: ripgrep, platform: platform{os: "windows", arch: "amd64"}, expectOut: "ripgrep-1.0.0-x86_64-pc-windows-msvc.zip"},
+		{release: ripgrep, platform: platform{os: "darwin", arch: "arm64"}, expectErr: true},
+		{release: tkn, platform: platform{os: "linux", arch: "amd64"}, expectOut: "tkn_1.0.0_Linux_x86_64.tar.gz"},
+		{release: tkn, platform: platform{os: "darwin", arch: "arm64"}, expectOut: "tkn_1.0.0_Darwin_all.tar.gz"},
+		{release: tkn, platform: platform{os: "freebsd", arch: "amd64"}, expectErr: true},
+		// a arm build that scores higher than a build without arch is never picked
+		{release: newRelease("tool_linux.tar.gz", "tool_linux_arm_static.tar.gz"), platform: platform{os: "linux", arch: "amd64"}, expectOut: "tool_linux.tar.gz"},
+		{release: newRelease("tool_linux_amd64", "tool_linux_arm.tar.gz", "tool_linux_arm64.tar.gz"), platform: platform{os: "linux", arch: "arm"}, expectOut: "tool_linux_arm.tar.gz"},
+		// win32 is a 32 bit build, not a windows alias
+		{release: newRelease("tool-win32.zip", "tool-win64.zip"), platform: platform{os: "windows", arch: "amd64"}, expectOut: "tool-win64.zip"},
+		// same score is an error
+		{release: newRelease("tool_linux_amd64.tar.gz", "tool-linux-x86_64.tar.gz"), platform: platform{os: "linux", arch: "amd64"}, expectErr: true},
+		{release: newRelease("tool_linux_amd64.tar.gz", "tool-linux-x86_64.tar.gz"), platform: platform{os: "linux", arch: "amd64"}, exclude: []string{"x86_64"}, expectOut: "tool_linux_amd64.tar.gz"},
+	}
+
+	for _, tests := range tests {
+		asset, err := selectAsset(config.Bin{Cli: "tool", Exclude: tests.exclude}, tests.release, tests.platform)
+		if tests.expectErr {
+			assert.Error(t, err, tests.platform.String())
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unable to select asset for %v, err: %v", tests.platform, err)
+			continue
+		}
+		assert.Equal(t, tests.expectOut, asset.GetName(), tests.platform.String())
+	}
+}
+
+func TestScoreAsset(t *testing.T) {
+	linux := platform{os: "linux", arch: "amd64"}
+	s := scoreAsset(newAssetMatcher(config.Bin{}, linux), &github.ReleaseAsset{Name: github.String("tool-x86_64-unknown-linux-gnu.tar.gz")})
+	assert.Equal(t, "", s.skip)
+	assert.Equal(t, 3+scoreOS+scoreArch+scoreGNULibc, s.score)
+
+	s = scoreAsset(newAssetMatcher(config.Bin{}, linux), &github.ReleaseAsset{Name: github.String("tool-linux-i686.tar.gz")})
+	assert.Equal(t, "built for another arch", s.skip)
+
+	s = scoreAsset(newAssetMatcher(config.Bin{}, linux), &github.ReleaseAsset{Name: github.String("tool-linux-amd64.snap")})
+	assert.NotEqual(t, "", s.skip)
+
+	// a binary named after the version have no extension
+	s = scoreAsset(newAssetMatcher(config.Bin{}, linux), &github.ReleaseAsset{Name: github.String("tool-v1.2.3-linux-amd64")})
+	assert.Equal(t, "", s.skip)
+
+	// plain arm is another arch, arm64 isn't mistaken for arm
+	s = scoreAsset(newAssetMatcher(config.Bin{}, linux), &github.ReleaseAsset{Name: github.String("tool_linux_arm.tar.gz")})
+	assert.Equal(t, "built for another arch", s.skip)
+	arm := platform{os: "linux", arch: "arm"}
+	s = scoreAsset(newAssetMatcher(config.Bin{}, arm), &github.ReleaseAsset{Name: github.String("tool_linux_arm.tar.gz")})
+	assert.Equal(t, 3+scoreOS+scoreArch, s.score)
+	s = scoreAsset(newAssetMatcher(config.Bin{}, arm), &github.ReleaseAsset{Name: github.String("tool_linux_arm64.tar.gz")})
+	assert.Equal(t, "built for another arch", s.skip)
+
+	// the aliases of the bin is used
+	s = scoreAsset(newAssetMatcher(config.Bin{OSAliases: map[string][]string{"linux": {"gnulinux"}}}, linux), &github.ReleaseAsset{Name: github.String("tool-gnulinux-amd64.zip")})
+	assert.Equal(t, 2+scoreOS+scoreArch, s.score)
+}
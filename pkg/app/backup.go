@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/NissesSenap/gitHubBinDl/pkg/util"
+	"github.com/go-logr/logr"
+	"github.com/spf13/viper"
+)
+
+// backup a old version of a cli created by copyOldCli
+type backup struct {
+	name     string
+	location string
+	// suffix is what got added after <cli>_, the version or the time of the backup
+	suffix  string
+	modTime time.Time
+}
+
+// backupLocation returns where backups are saved, where the clis is installed is used if no backupLocation is set
+func backupLocation() string {
+	if location := viper.GetString(config.DefaultBackupLocationKey); location != "" {
+		return location
+	}
+	return binLocation()
+}
+
+// backupVersionRegex a version with at least major.minor at the end, like v0.15.0 or kustomize-v5.0.0
+var backupVersionRegex = regexp.MustCompile(`(?:^|[^0-9A-Za-z])[vV]?\d+\.\d+[0-9A-Za-z.+-]*$`)
+
+// backupVersion turns the version in to the part of the backup name, / and _ is replaced so the version can't be mistaken for another cli.
+// A version that don't look like a version gives "" and the time is used instead.
+func backupVersion(version string) string {
+	version = strings.NewReplacer("/", "-", "_", "-").Replace(version)
+	if !backupVersionRegex.MatchString(version) {
+		return ""
+	}
+	return version
+}
+
+// isBackupSuffix returns true if the suffix is one that copyOldCli writes: a version, a version and a time or only a time.
+// Older versions of githubbindl only used the date. Other clis in the same folder, like tool_helper next to tool, is never a backup.
+func isBackupSuffix(suffix string) bool {
+	for _, format := range []string{util.DateTimeFormat, util.DateFormat} {
+		if _, err := time.Parse(format, suffix); err == nil {
+			return true
+		}
+	}
+	if len(suffix) > len(util.DateTimeFormat) {
+		split := len(suffix) - len(util.DateTimeFormat) - 1
+		if _, err := time.Parse(util.DateTimeFormat, suffix[split+1:]); err == nil && suffix[split] == '_' {
+			suffix = suffix[:split]
+		}
+	}
+	return backupVersion(suffix) == suffix
+}
+
+// listBackups returns all backups of the cli, the newest first
+func listBackups(cliName, backupLocation string) ([]backup, error) {
+	matches, err := filepath.Glob(filepath.Join(backupLocation, cliName+"_*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backup
+	for _, match := range matches {
+		stat, err := os.Stat(match)
+		if err != nil {
+			return nil, err
+		}
+		if !stat.Mode().IsRegular() {
+			continue
+		}
+		suffix := strings.TrimPrefix(filepath.Base(match), cliName+"_")
+		if !isBackupSuffix(suffix) {
+			continue
+		}
+		backups = append(backups, backup{name: filepath.Base(match), location: match, suffix: suffix, modTime: stat.ModTime()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+	return backups, nil
+}
+
+// pruneBackups removes backups of the cli that is older than maxAge or more than keep, nothing is removed if retention isn't set
+func pruneBackups(ctx context.Context, cliName, backupLocation string, retention config.Retention) error {
+	log := logr.FromContext(ctx)
+
+	maxAge, err := retention.MaxAgeDuration()
+	if err != nil {
+		return err
+	}
+	if retention.Keep <= 0 && maxAge <= 0 {
+		return nil
+	}
+
+	backups, err := listBackups(cliName, backupLocation)
+	if err != nil {
+		return err
+	}
+
+	for i, b := range backups {
+		if (retention.Keep > 0 && i >= retention.Keep) || (maxAge > 0 && time.Since(b.modTime) > maxAge) {
+			log.Info("Removing old backup", "cli", cliName, "backup", b.location)
+			if err := os.Remove(b.location); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// two updates of the same version don't overwrite each other and prune keeps the newest
+func TestBackupAndPrune(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+
+	workspace := getEnv("TEMP_DIR", "/tmp")
+	saveLocation, err := ioutil.TempDir(workspace, "testBackup")
+	if err != nil {
+		t.Fatalf("Unable to create a tmp dir %v", err)
+	}
+	backupLocation := filepath.Join(saveLocation, "backups")
+	if err := os.Mkdir(backupLocation, 0755); err != nil {
+		t.Fatalf("Unable to create backup dir %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(saveLocation, "kustomize"), []byte("v5"), 0755); err != nil {
+		t.Fatalf("Unable to create file %v", err)
+	}
+
+	assert.NoError(t, copyOldCli("kustomize", saveLocation, backupLocation, "kustomize/v5.0.0"))
+	assert.NoError(t, copyOldCli("kustomize", saveLocation, backupLocation, "kustomize/v5.0.0"))
+	assert.NoError(t, copyOldCli("kustomize", saveLocation, backupLocation, ""))
+
+	backups, err := listBackups("kustomize", backupLocation)
+	assert.NoError(t, err)
+	assert.Len(t, backups, 3)
+	_, err = os.Stat(filepath.Join(backupLocation, "kustomize_kustomize-v5.0.0"))
+	assert.NoError(t, err)
+
+	// make one of them old
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(backups[2].location, old, old))
+
+	assert.NoError(t, pruneBackups(ctx, "kustomize", backupLocation, config.Retention{MaxAge: "1d"}))
+	backups, _ = listBackups("kustomize", backupLocation)
+	assert.Len(t, backups, 2)
+
+	assert.NoError(t, pruneBackups(ctx, "kustomize", backupLocation, config.Retention{}))
+	backups, _ = listBackups("kustomize", backupLocation)
+	assert.Len(t, backups, 2)
+
+	assert.NoError(t, pruneBackups(ctx, "kustomize", backupLocation, config.Retention{Keep: 1}))
+	backups, _ = listBackups("kustomize", backupLocation)
+	assert.Len(t, backups, 1)
+
+	assert.Error(t, pruneBackups(ctx, "kustomize", backupLocation, config.Retention{MaxAge: "ten days"}))
+}
+
+// clis that share a prefix, like tool and tool_helper, never see each others files as backups
+func TestListBackupsSharedPrefix(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+
+	workspace := getEnv("TEMP_DIR", "/tmp")
+	folder, err := ioutil.TempDir(workspace, "testBackupPrefix")
+	if err != nil {
+		t.Fatalf("Unable to create a tmp dir %v", err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	files := []struct {
+		name    string
+		modTime time.Time
+	}{
+		{name: "tool", modTime: time.Now()},
+		{name: "tool_v1.0.0", modTime: time.Now()},
+		{name: "tool_v0.9.0_2021-01-10_134501", modTime: old},
+		{name: "tool_2021-01-10", modTime: old},
+		{name: "tool_helper", modTime: old},
+		{name: "tool_helper_v2.0.0", modTime: old},
+		{name: "tool_helper_2021-01-10_134501", modTime: old},
+		{name: "tool_v2", modTime: old},
+	}
+	for _, file := range files {
+		location := filepath.Join(folder, file.name)
+		if err := ioutil.WriteFile(location, []byte(file.name), 0755); err != nil {
+			t.Fatalf("Unable to create file %v", err)
+		}
+		if err := os.Chtimes(location, file.modTime, file.modTime); err != nil {
+			t.Fatalf("Unable to change time of file %v", err)
+		}
+	}
+
+	backups, err := listBackups("tool", folder)
+	assert.NoError(t, err)
+	var names []string
+	for _, b := range backups {
+		names = append(names, b.name)
+	}
+	assert.ElementsMatch(t, []string{"tool_v1.0.0", "tool_v0.9.0_2021-01-10_134501", "tool_2021-01-10"}, names)
+
+	backups, err = listBackups("tool_helper", folder)
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+
+	// keep: 1 only removes backups of tool
+	assert.NoError(t, pruneBackups(ctx, "tool", folder, config.Retention{Keep: 1}))
+	for _, name := range []string{"tool", "tool_v1.0.0", "tool_helper", "tool_helper_v2.0.0", "tool_helper_2021-01-10_134501", "tool_v2"} {
+		_, err := os.Stat(filepath.Join(folder, name))
+		assert.NoError(t, err, name)
+	}
+
+	assert.Equal(t, "kustomize-v5.0.0", backupVersion("kustomize/v5.0.0"))
+	assert.Equal(t, "release-1.2", backupVersion("release_1.2"))
+	assert.Equal(t, "", backupVersion("nightly"))
+}
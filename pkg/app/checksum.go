@@ -0,0 +1,176 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v33/github"
+)
+
+// releaseFile a file in the same release as the asset, like a checksum file
+type releaseFile struct {
+	name string
+	id   int64
+	url  string
+}
+
+var (
+	// checksumFileName matches release wide checksum files like checksums.txt, SHA256SUMS and tool_1.0.0_checksums.txt
+	checksumFileName = regexp.MustCompile(`(?i)(^|[._-])(checksums?|sha256sums?|sha512sums?|shasums?)([._-]|$)`)
+	// bsdChecksum matches SHA256 (tool.tar.gz) = <hex>
+	bsdChecksum = regexp.MustCompile(`^(SHA256|SHA512) ?\((.+)\) ?= ?([0-9a-fA-F]+)$`)
+	hexDigest   = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+)
+
+// assetChecksumSuffixes checksum files for a single asset, example: tool.tar.gz.sha256
+var assetChecksumSuffixes = []string{".sha256", ".sha256sum", ".sha512", ".sha512sum"}
+
+// findChecksumFile returns the checksum file for the asset in the release.
+// checksumAsset is a regex that picks the file, else a <asset>.sha256 file is used before a release wide checksums file.
+func findChecksumFile(binConfig config.Bin, release *github.RepositoryRelease, asset *github.ReleaseAsset) (*releaseFile, error) {
+	found, err := findReleaseFile(binConfig.Cli, release, asset.GetName(), binConfig.ChecksumAsset, assetChecksumSuffixes)
+	if err != nil || found != nil || binConfig.ChecksumAsset != "" {
+		return found, err
+	}
+
+	// sha256 before sha512 if the release have both
+	for _, a := range release.Assets {
+		name := strings.ToLower(a.GetName())
+		if a.GetID() == asset.GetID() || !checksumFileName.MatchString(name) || isSignature(name) || followsOtherAsset(name, release, a) {
+			continue
+		}
+		if found == nil || (strings.Contains(name, "256") && !strings.Contains(strings.ToLower(found.name), "256")) {
+			found = &releaseFile{name: a.GetName(), id: a.GetID(), url: a.GetBrowserDownloadURL()}
+		}
+	}
+	return found, nil
+}
+
+// followsOtherAsset returns true if the name is the name of another asset in the release with a extension added,
+// example: other_linux_arm64.tar.gz.sha256sum is the checksum of other_linux_arm64.tar.gz and not a release wide checksum file
+func followsOtherAsset(name string, release *github.RepositoryRelease, self *github.ReleaseAsset) bool {
+	for _, a := range release.Assets {
+		other := strings.ToLower(a.GetName())
+		if a.GetID() != self.GetID() && strings.HasPrefix(name, other+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// isSignature returns true for signatures and certificates of a checksum file, example: checksums.txt.sig
+func isSignature(name string) bool {
+	for _, suffix := range []string{".sig", ".asc", ".pem", ".crt", ".cert", ".minisig", ".sigstore.json", ".bundle"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseChecksum finds the digest of the asset in a checksum file.
+// GNU coreutils (<hex>  tool.tar.gz or <hex> *tool.tar.gz), BSD (SHA256 (tool.tar.gz) = <hex>) and a file with only the hex digest is supported.
+// The length of the digest decides if it's sha256 or sha512.
+func parseChecksum(content []byte, assetName string) (string, bool, error) {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", false, err
+	}
+
+	// a bare digest belongs to whatever asset the file was made for
+	if len(lines) == 1 && hexDigest.MatchString(lines[0]) {
+		return strings.ToLower(lines[0]), true, nil
+	}
+
+	for _, line := range lines {
+		var name, digest string
+		if match := bsdChecksum.FindStringSubmatch(line); match != nil {
+			name, digest = match[2], match[3]
+		} else {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || !hexDigest.MatchString(fields[0]) {
+				continue
+			}
+			digest = fields[0]
+			name = strings.TrimPrefix(strings.Join(fields[1:], " "), "*")
+		}
+		if path.Base(name) == assetName {
+			return strings.ToLower(digest), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// digestMatches compares the digest with the sha256 or sha512 of the data
+func digestMatches(digest string, data blob) (bool, error) {
+	var sum []byte
+	var err error
+	switch len(digest) {
+	case sha256.Size * 2:
+		sum, err = blobSHA256(data)
+	case sha512.Size * 2:
+		sum, err = blobDigest(data, sha512.New())
+	default:
+		return false, fmt.Errorf("unsupported digest length %v, only sha256 and sha512 is supported", len(digest))
+	}
+	if err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(sum) == digest, nil
+}
+
+// verifyChecksum verifies the download against the checksum file of the release before anything is written.
+// A mismatch is always a error, a missing checksum is only a error if required is set.
+func verifyChecksum(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, entry lockEntry, data blob, required bool) error {
+	log := logr.FromContext(ctx)
+
+	if entry.checksumFile == nil {
+		if required {
+			return fmt.Errorf("%v: requireChecksum is set but no checksum file was found for %v", binConfig.Cli, entry.AssetName)
+		}
+		log.V(1).Info("No checksum file found, skipping checksum verification", "cli", binConfig.Cli, "asset", entry.AssetName)
+		return nil
+	}
+
+	content, _, err := fetchAsset(ctx, client, httpClient, binConfig, lockEntry{AssetID: entry.checksumFile.id, DownloadURL: entry.checksumFile.url})
+	if err != nil {
+		return fmt.Errorf("%v: unable to download checksum file %v: %v", binConfig.Cli, entry.checksumFile.name, err)
+	}
+	digest, found, err := parseChecksum(content, entry.AssetName)
+	if err != nil {
+		return fmt.Errorf("%v: unable to parse checksum file %v: %v", binConfig.Cli, entry.checksumFile.name, err)
+	}
+	if !found {
+		if required {
+			return fmt.Errorf("%v: %v is missing in checksum file %v", binConfig.Cli, entry.AssetName, entry.checksumFile.name)
+		}
+		log.Info("Asset is missing in the checksum file, skipping checksum verification", "cli", binConfig.Cli, "asset", entry.AssetName, "checksumFile", entry.checksumFile.name)
+		return nil
+	}
+
+	matches, err := digestMatches(digest, data)
+	if err != nil {
+		return fmt.Errorf("%v: %v in %v", binConfig.Cli, err, entry.checksumFile.name)
+	}
+	if !matches {
+		return fmt.Errorf("%v: checksum mismatch for %v, %v says %v", binConfig.Cli, entry.AssetName, entry.checksumFile.name, digest)
+	}
+	log.Info("Verified checksum", "cli", binConfig.Cli, "asset", entry.AssetName, "checksumFile", entry.checksumFile.name)
+	return nil
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/google/go-github/v33/github"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChecksum(t *testing.T) {
+	digest := "3f5e2a3ac2bd8e3e56d2ea6c9e7c2b3d0a3c8d4c4c2e54f3f0b2d7c8d1e3a4b5"
+
+	tests := []struct {
+		content     string
+		assetName   string
+		expectOut   string
+		expectFound bool
+	}{
+		{content: "aaaa  other.tar.gz\n" + digest + "  tool_linux_amd64.tar.gz\n", assetName: "tool_linux_amd64.tar.gz", expectOut: digest, expectFound: true},
+		{content: digest + " *tool_linux_amd64.tar.gz\n", assetName: "tool_linux_amd64.tar.gz", expectOut: digest, expectFound: true},
+		{content: digest + "  ./dist/tool_linux_amd64.tar.gz\n", assetName: "tool_linux_amd64.tar.gz", expectOut: digest, expectFound: true},
+		{content: "# comment\nSHA256 (tool_linux_amd64.tar.gz) = " + digest + "\n", assetName: "tool_linux_amd64.tar.gz", expectOut: digest, expectFound: true},
+		{content: "SHA256(tool_linux_amd64.tar.gz)= " + digest, assetName: "tool_linux_amd64.tar.gz", expectOut: digest, expectFound: true},
+		{content: "  " + "3F5E2A3AC2BD8E3E56D2EA6C9E7C2B3D0A3C8D4C4C2E54F3F0B2D7C8D1E3A4B5" + "\n", assetName: "tool_linux_amd64.tar.gz", expectOut: digest, expectFound: true},
+		{content: digest + "  tool_darwin_amd64.tar.gz\n", assetName: "tool_linux_amd64.tar.gz", expectFound: false},
+		{content: "", assetName: "tool_linux_amd64.tar.gz", expectFound: false},
+	}
+
+	for _, tests := range tests {
+		digest, found, err := parseChecksum([]byte(tests.content), tests.assetName)
+		if err != nil {
+			t.Errorf("Unable to parse %q, err: %v", tests.content, err)
+			continue
+		}
+		assert.Equal(t, tests.expectFound, found, tests.content)
+		assert.Equal(t, tests.expectOut, digest, tests.content)
+	}
+}
+
+func TestFindChecksumFile(t *testing.T) {
+	asset := &github.ReleaseAsset{ID: github.Int64(1), Name: github.String("tool_linux_amd64.tar.gz")}
+	release := func(names ...string) *github.RepositoryRelease {
+		r := &github.RepositoryRelease{TagName: github.String("v1.0.0"), Assets: []*github.ReleaseAsset{asset}}
+		for i, name := range names {
+			r.Assets = append(r.Assets, &github.ReleaseAsset{ID: github.Int64(int64(i + 2)), Name: github.String(name)})
+		}
+		return r
+	}
+
+	tests := []struct {
+		binConfig config.Bin
+		release   *github.RepositoryRelease
+		expectOut string
+		expectErr bool
+	}{
+		{release: release("tool_darwin_amd64.tar.gz", "tool_1.0.0_checksums.txt"), expectOut: "tool_1.0.0_checksums.txt"},
+		{release: release("SHA512SUMS", "SHA256SUMS", "SHA256SUMS.sig"), expectOut: "SHA256SUMS"},
+		{release: release("checksums.txt", "tool_linux_amd64.tar.gz.sha256"), expectOut: "tool_linux_amd64.tar.gz.sha256"},
+		{release: release("checksums.txt.sig", "tool_darwin_amd64.tar.gz"), expectOut: ""},
+		{release: release("other_linux_arm64.tar.gz", "other_linux_arm64.tar.gz.sha256sum"), expectOut: ""},
+		{release: release("other_linux_arm64.tar.gz", "other_linux_arm64.tar.gz.sha256sum", "SHA256SUMS"), expectOut: "SHA256SUMS"},
+		{release: release("tool", "tool_1.0.0_checksums.txt"), expectOut: "tool_1.0.0_checksums.txt"},
+		{binConfig: config.Bin{ChecksumAsset: `^hashes\.txt$`}, release: release("checksums.txt", "hashes.txt"), expectOut: "hashes.txt"},
+		{binConfig: config.Bin{ChecksumAsset: `^hashes\.txt$`}, release: release("checksums.txt"), expectErr: true},
+		{binConfig: config.Bin{ChecksumAsset: `(`}, release: release("checksums.txt"), expectErr: true},
+	}
+
+	for _, tests := range tests {
+		found, err := findChecksumFile(tests.binConfig, tests.release, asset)
+		if tests.expectErr {
+			assert.Error(t, err, tests.binConfig.ChecksumAsset)
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unable to find checksum file, err: %v", err)
+			continue
+		}
+		if tests.expectOut == "" {
+			assert.Nil(t, found)
+			continue
+		}
+		if assert.NotNil(t, found, tests.expectOut) {
+			assert.Equal(t, tests.expectOut, found.name)
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("the asset")
+	sum := sha256.Sum256(data)
+	sum512 := sha512.Sum512(data)
+
+	checksums := map[string]string{
+		"/checksums.txt": hex.EncodeToString(sum[:]) + "  tool.tar.gz\n",
+		"/wrong.txt":     hex.EncodeToString(sum[:1]) + hex.EncodeToString(sum[:31]) + "  tool.tar.gz\n",
+		"/sha512.txt":    "SHA512 (tool.tar.gz) = " + hex.EncodeToString(sum512[:]) + "\n",
+		"/other.txt":     hex.EncodeToString(sum[:]) + "  other.tar.gz\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := checksums[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	viper.Set(config.DefaultHTTPtimeoutkey, 5)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024*1024)
+
+	tests := []struct {
+		checksumFile string
+		require      bool
+		expectErr    bool
+	}{
+		{checksumFile: "/checksums.txt"},
+		{checksumFile: "/sha512.txt", require: true},
+		{checksumFile: "/wrong.txt", expectErr: true},
+		{checksumFile: "/missing.txt", expectErr: true},
+		{checksumFile: "/other.txt"},
+		{checksumFile: "/other.txt", require: true, expectErr: true},
+		{},
+		{require: true, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		binConfig := config.Bin{Cli: "tool"}
+		entry := lockEntry{AssetName: "tool.tar.gz"}
+		if tests.checksumFile != "" {
+			entry.checksumFile = &releaseFile{name: tests.checksumFile, url: server.URL + tests.checksumFile}
+		}
+		err := verifyChecksum(ctx, nil, server.Client(), binConfig, entry, bytesBlob(data), tests.require)
+		if tests.expectErr {
+			assert.Error(t, err, tests.checksumFile)
+			continue
+		}
+		assert.NoError(t, err, tests.checksumFile)
+	}
+}
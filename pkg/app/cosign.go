@@ -0,0 +1,527 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v33/github"
+)
+
+// Fulcio certificate extensions with the OIDC issuer, the first is deprecated but still used by older certificates
+var (
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// the default names of the cosign files next to the asset, example: tool.tar.gz.sig
+var (
+	cosignSignatureSuffixes   = []string{".sig"}
+	cosignCertificateSuffixes = []string{".pem", ".crt", ".cert"}
+	cosignBundleSuffixes      = []string{".sigstore.json", ".sigstore", ".bundle"}
+)
+
+// cosignMaterial what is needed to verify a blob signature, certs and tlogEntries is only used for keyless signatures
+type cosignMaterial struct {
+	signature []byte
+	// certs is the leaf certificate first and any intermediates after it
+	certs []*x509.Certificate
+	// digest is the sha256 of the blob according to a sigstore bundle
+	digest []byte
+	// tlogEntries is the transparency log entries from a bundle
+	tlogEntries []tlogEntry
+}
+
+// sigstoreBundle the parts of a sigstore bundle (.sigstore.json) that is used, v0.1 to v0.3 is supported
+type sigstoreBundle struct {
+	MediaType            string `json:"mediaType"`
+	VerificationMaterial struct {
+		X509CertificateChain *struct {
+			Certificates []struct {
+				RawBytes []byte `json:"rawBytes"`
+			} `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		Certificate *struct {
+			RawBytes []byte `json:"rawBytes"`
+		} `json:"certificate"`
+		TlogEntries []struct {
+			LogIndex string `json:"logIndex"`
+			LogID    struct {
+				KeyID []byte `json:"keyId"`
+			} `json:"logId"`
+			IntegratedTime   string `json:"integratedTime"`
+			InclusionPromise *struct {
+				SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+			} `json:"inclusionPromise"`
+			CanonicalizedBody []byte `json:"canonicalizedBody"`
+		} `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	MessageSignature *struct {
+		MessageDigest struct {
+			Algorithm string `json:"algorithm"`
+			Digest    []byte `json:"digest"`
+		} `json:"messageDigest"`
+		Signature []byte `json:"signature"`
+	} `json:"messageSignature"`
+	DSSEEnvelope *dsseEnvelope `json:"dsseEnvelope"`
+}
+
+// verificationMaterial returns the certificates and the transparency log entries in the bundle
+func (b sigstoreBundle) verificationMaterial() ([]*x509.Certificate, []tlogEntry, error) {
+	verification := b.VerificationMaterial
+	var rawCerts [][]byte
+	if verification.Certificate != nil {
+		rawCerts = append(rawCerts, verification.Certificate.RawBytes)
+	}
+	if verification.X509CertificateChain != nil {
+		for _, cert := range verification.X509CertificateChain.Certificates {
+			rawCerts = append(rawCerts, cert.RawBytes)
+		}
+	}
+	var certs []*x509.Certificate
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	var entries []tlogEntry
+	for _, e := range verification.TlogEntries {
+		integratedTime, err := strconv.ParseInt(e.IntegratedTime, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid integratedTime %v: %v", e.IntegratedTime, err)
+		}
+		logIndex, err := strconv.ParseInt(e.LogIndex, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid logIndex %v: %v", e.LogIndex, err)
+		}
+		entry := tlogEntry{logIndex: logIndex, logID: e.LogID.KeyID, integratedTime: integratedTime, body: e.CanonicalizedBody}
+		if e.InclusionPromise != nil {
+			entry.signedEntryTimestamp = e.InclusionPromise.SignedEntryTimestamp
+		}
+		entries = append(entries, entry)
+	}
+	return certs, entries, nil
+}
+
+// cosignBundle the bundle written by cosign sign-blob --bundle
+type cosignBundle struct {
+	Base64Signature string `json:"base64Signature"`
+	// Cert is a base64 encoded PEM certificate
+	Cert        string `json:"cert"`
+	RekorBundle *struct {
+		SignedEntryTimestamp []byte `json:"SignedEntryTimestamp"`
+		Payload              struct {
+			// Body is the base64 encoded canonicalized entry
+			Body           string `json:"body"`
+			IntegratedTime int64  `json:"integratedTime"`
+			LogIndex       int64  `json:"logIndex"`
+			// LogID is hex encoded
+			LogID string `json:"logID"`
+		} `json:"Payload"`
+	} `json:"rekorBundle"`
+}
+
+// verifyCosign verifies the cosign signature of the download.
+// With key the signature is checked with the public key, else the certificate have to chain up to trustRoot and be issued to identity by issuer.
+func verifyCosign(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, entry lockEntry, data blob) error {
+	log := logr.FromContext(ctx)
+	cosign := binConfig.Verify.Cosign
+
+	keyless := cosign.Identity != "" || cosign.IdentityRegexp != "" || cosign.Issuer != "" || cosign.TrustRoot != ""
+	switch {
+	case cosign.Key != "" && keyless:
+		return fmt.Errorf("%v: cosign key can't be used together with identity, identityRegexp, issuer and trustRoot", binConfig.Cli)
+	case cosign.Key == "" && (cosign.Identity == "" && cosign.IdentityRegexp == "" || cosign.Issuer == "" || cosign.TrustRoot == ""):
+		return fmt.Errorf("%v: cosign needs a key, or identity or identityRegexp together with issuer and trustRoot", binConfig.Cli)
+	}
+
+	material, source, err := fetchCosignMaterial(ctx, client, httpClient, binConfig, entry)
+	if err != nil {
+		return err
+	}
+	sum, err := blobSHA256(data)
+	if err != nil {
+		return err
+	}
+	if material.digest != nil && !bytes.Equal(material.digest, sum) {
+		return fmt.Errorf("%v: the digest in %v don't match %v", binConfig.Cli, source, entry.AssetName)
+	}
+
+	var publicKey crypto.PublicKey
+	if cosign.Key != "" {
+		publicKey, err = readPublicKey(expandHome(cosign.Key))
+		if err != nil {
+			return fmt.Errorf("%v: unable to read cosign key %v: %v", binConfig.Cli, cosign.Key, err)
+		}
+	} else {
+		if len(material.certs) == 0 {
+			return fmt.Errorf("%v: %v don't contain a certificate, needed for keyless verification", binConfig.Cli, source)
+		}
+		if err := verifyCertificate(cosign, material, sum); err != nil {
+			return fmt.Errorf("%v: certificate in %v: %v", binConfig.Cli, source, err)
+		}
+		publicKey = material.certs[0].PublicKey
+	}
+
+	if err := verifyBlobSignature(publicKey, data, material.signature); err != nil {
+		return fmt.Errorf("%v: cosign signature %v is not valid for %v: %v", binConfig.Cli, source, entry.AssetName, err)
+	}
+	log.Info("Verified cosign signature", "cli", binConfig.Cli, "asset", entry.AssetName, "signature", source)
+	return nil
+}
+
+// fetchCosignMaterial downloads a bundle, or a signature and a certificate if there is no bundle.
+// The name of the file with the signature is returned to be used in errors.
+func fetchCosignMaterial(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, entry lockEntry) (cosignMaterial, string, error) {
+	cosign := binConfig.Verify.Cosign
+
+	// a configured signature means that no bundle should be looked for
+	if cosign.Signature == "" {
+		bundle, err := fetchSidecar(ctx, client, httpClient, binConfig, entry, cosign.Bundle, cosignBundleSuffixes)
+		if err != nil {
+			return cosignMaterial{}, "", err
+		}
+		if bundle != nil {
+			material, err := parseCosignBundle(bundle.data)
+			if err != nil {
+				return cosignMaterial{}, "", fmt.Errorf("%v: unable to parse bundle %v: %v", binConfig.Cli, bundle.name, err)
+			}
+			return material, bundle.name, nil
+		}
+	}
+
+	signature, err := fetchSidecar(ctx, client, httpClient, binConfig, entry, cosign.Signature, cosignSignatureSuffixes)
+	if err != nil {
+		return cosignMaterial{}, "", err
+	}
+	if signature == nil {
+		return cosignMaterial{}, "", fmt.Errorf("%v: unable to find a cosign signature or bundle for %v", binConfig.Cli, entry.AssetName)
+	}
+	material := cosignMaterial{signature: decodeSignature(signature.data)}
+
+	if cosign.Key == "" {
+		certificate, err := fetchSidecar(ctx, client, httpClient, binConfig, entry, cosign.Certificate, cosignCertificateSuffixes)
+		if err != nil {
+			return cosignMaterial{}, "", err
+		}
+		if certificate == nil {
+			return cosignMaterial{}, "", fmt.Errorf("%v: unable to find a certificate for %v, needed for keyless verification", binConfig.Cli, signature.name)
+		}
+		material.certs, err = parseCertificates(certificate.data)
+		if err != nil {
+			return cosignMaterial{}, "", fmt.Errorf("%v: unable to parse certificate %v: %v", binConfig.Cli, certificate.name, err)
+		}
+	}
+	return material, signature.name, nil
+}
+
+// parseCosignBundle parses both a sigstore bundle and a bundle from cosign sign-blob --bundle
+func parseCosignBundle(data []byte) (cosignMaterial, error) {
+	var material cosignMaterial
+
+	var bundle sigstoreBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return material, err
+	}
+	if bundle.MediaType != "" {
+		if !strings.HasPrefix(bundle.MediaType, "application/vnd.dev.sigstore.bundle") {
+			return material, fmt.Errorf("unsupported bundle %v", bundle.MediaType)
+		}
+		if bundle.MessageSignature == nil {
+			return material, errors.New("the bundle don't contain a message signature, only signed blobs is supported")
+		}
+		if algorithm := bundle.MessageSignature.MessageDigest.Algorithm; algorithm != "" && algorithm != "SHA2_256" {
+			return material, fmt.Errorf("unsupported digest algorithm %v", algorithm)
+		}
+		material.signature = bundle.MessageSignature.Signature
+		material.digest = bundle.MessageSignature.MessageDigest.Digest
+		var err error
+		material.certs, material.tlogEntries, err = bundle.verificationMaterial()
+		return material, err
+	}
+
+	var legacy cosignBundle
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return material, err
+	}
+	if legacy.Base64Signature == "" {
+		return material, errors.New("unknown bundle format, expected a sigstore bundle or a cosign bundle")
+	}
+	signature, err := base64.StdEncoding.DecodeString(legacy.Base64Signature)
+	if err != nil {
+		return material, err
+	}
+	material.signature = signature
+	if legacy.Cert != "" {
+		material.certs, err = parseCertificates([]byte(legacy.Cert))
+		if err != nil {
+			return material, err
+		}
+	}
+	if legacy.RekorBundle != nil {
+		rekor := legacy.RekorBundle
+		body, err := base64.StdEncoding.DecodeString(rekor.Payload.Body)
+		if err != nil {
+			return material, fmt.Errorf("invalid rekorBundle body: %v", err)
+		}
+		logID, err := hex.DecodeString(rekor.Payload.LogID)
+		if err != nil {
+			return material, fmt.Errorf("invalid rekorBundle logID: %v", err)
+		}
+		material.tlogEntries = []tlogEntry{{
+			logIndex:             rekor.Payload.LogIndex,
+			logID:                logID,
+			integratedTime:       rekor.Payload.IntegratedTime,
+			body:                 body,
+			signedEntryTimestamp: rekor.SignedEntryTimestamp,
+		}}
+	}
+	return material, nil
+}
+
+// decodeSignature cosign writes the signature base64 encoded, anything that isn't base64 is used as it is
+func decodeSignature(data []byte) []byte {
+	if signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data))); err == nil {
+		return signature
+	}
+	return data
+}
+
+// parseCertificates parses PEM certificates, cosign writes the PEM base64 encoded so that is supported as well
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, errors.New("expected a PEM or a base64 encoded PEM certificate")
+		}
+		data = decoded
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return certs, nil
+}
+
+// readPublicKey reads a PEM encoded public key like cosign.pub
+func readPublicKey(location string) (crypto.PublicKey, error) {
+	data, err := ioutil.ReadFile(location) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// verifyCertificate checks that the leaf certificate chains up to the trust root at the verified log time,
+// and that it's issued to the expected identity by the expected issuer. digest is the sha256 of the blob.
+func verifyCertificate(cosign *config.Cosign, material cosignMaterial, digest []byte) error {
+	root, err := readTrustRoot(expandHome(cosign.TrustRoot))
+	if err != nil {
+		return fmt.Errorf("unable to read trustRoot %v: %v", cosign.TrustRoot, err)
+	}
+	signedAt, err := root.logTime(material.tlogEntries, digest, material.signature, material.certs[0])
+	if err != nil {
+		return err
+	}
+	if err := verifyCertificateChain(root, material.certs, signedAt); err != nil {
+		return err
+	}
+
+	var identityRegexp *regexp.Regexp
+	if cosign.IdentityRegexp != "" {
+		if identityRegexp, err = regexp.Compile(cosign.IdentityRegexp); err != nil {
+			return fmt.Errorf("invalid identityRegexp %q: %v", cosign.IdentityRegexp, err)
+		}
+	}
+	leaf := material.certs[0]
+	identities := certificateIdentities(leaf)
+	if !identityMatches(cosign.Identity, identityRegexp, identities) {
+		expected := cosign.Identity
+		if expected == "" {
+			expected = cosign.IdentityRegexp
+		}
+		return fmt.Errorf("issued to %v, expected %v", strings.Join(identities, ", "), expected)
+	}
+	return verifyIssuer(leaf, cosign.Issuer)
+}
+
+// verifyIssuer returns a error if the certificate isn't issued by the OIDC issuer
+func verifyIssuer(cert *x509.Certificate, expected string) error {
+	issuer, err := certificateIssuer(cert)
+	if err != nil {
+		return err
+	}
+	if issuer != expected {
+		return fmt.Errorf("issued by %q, expected %q", issuer, expected)
+	}
+	return nil
+}
+
+// verifyCertificateChain checks that the leaf, the first of certs, chains up to the trust root for code signing.
+// The certificate is short lived, it's checked at signedAt which have to be a verified transparency log time.
+func verifyCertificateChain(root trustedRoot, certs []*x509.Certificate, signedAt time.Time) error {
+	if signedAt.IsZero() {
+		return errors.New("no verified log time to check the certificate at")
+	}
+	roots := x509.NewCertPool()
+	intermediates := x509.NewCertPool()
+	for _, cert := range root.certs {
+		// self signed certificates is roots, the rest is intermediates
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil {
+			roots.AddCert(cert)
+			continue
+		}
+		intermediates.AddCert(cert)
+	}
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	return err
+}
+
+// certificateIdentities returns the emails and uris in the subject alternative name
+func certificateIdentities(cert *x509.Certificate) []string {
+	identities := append([]string{}, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	return identities
+}
+
+// identityMatches returns true if any of the identities is expected or matches expectedRegexp
+func identityMatches(expected string, expectedRegexp *regexp.Regexp, identities []string) bool {
+	for _, identity := range identities {
+		if (expected != "" && identity == expected) || (expectedRegexp != nil && expectedRegexp.MatchString(identity)) {
+			return true
+		}
+	}
+	return false
+}
+
+// certificateIssuer returns the OIDC issuer that Fulcio adds to the certificate
+func certificateIssuer(cert *x509.Certificate) (string, error) {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidIssuerV2) {
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err != nil {
+				return "", fmt.Errorf("invalid issuer extension: %v", err)
+			}
+			return issuer, nil
+		}
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidIssuerV1) {
+			return string(ext.Value), nil
+		}
+	}
+	return "", errors.New("the certificate don't contain a issuer")
+}
+
+// verifyBlobSignature verifies a signature like cosign sign-blob creates it, ecdsa and rsa signs the sha256 of the blob and ed25519 the sha512 (Ed25519ph)
+func verifyBlobSignature(publicKey crypto.PublicKey, data blob, signature []byte) error {
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		var digest []byte
+		var err error
+		switch key.Curve {
+		case elliptic.P384():
+			digest, err = blobDigest(data, sha512.New384())
+		case elliptic.P521():
+			digest, err = blobDigest(data, sha512.New())
+		default:
+			digest, err = blobSHA256(data)
+		}
+		if err != nil {
+			return err
+		}
+		var sig struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+			return fmt.Errorf("invalid ecdsa signature: %v", err)
+		}
+		if !ecdsa.Verify(key, digest, sig.R, sig.S) {
+			return errors.New("ecdsa verification failed")
+		}
+		return nil
+	case *rsa.PublicKey:
+		sum, err := blobSHA256(data)
+		if err != nil {
+			return err
+		}
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum, signature)
+	case ed25519.PublicKey:
+		digest, err := blobDigest(data, sha512.New())
+		if err != nil {
+			return err
+		}
+		if err := ed25519.VerifyWithOptions(key, digest, signature, &ed25519.Options{Hash: crypto.SHA512}); err != nil {
+			return errors.New("ed25519 verification failed")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported public key %T", publicKey)
+}
+
+// verifySignature verifies a signature of a message in memory like a DSSE envelope, ed25519 signs the message itself
+func verifySignature(publicKey crypto.PublicKey, message, signature []byte) error {
+	if key, ok := publicKey.(ed25519.PublicKey); ok {
+		if !ed25519.Verify(key, message, signature) {
+			return errors.New("ed25519 verification failed")
+		}
+		return nil
+	}
+	return verifyBlobSignature(publicKey, bytesBlob(message), signature)
+}
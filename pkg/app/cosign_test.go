@@ -0,0 +1,384 @@
+package app
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/google/go-github/v33/github"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestCA returns a self signed certificate authority, like the Fulcio root
+func newTestCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate key %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unable to create certificate %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Unable to parse certificate %v", err)
+	}
+	return cert, key
+}
+
+// newTestLeaf returns a short lived code signing certificate issued to identity by the OIDC issuer, like Fulcio creates them
+func newTestLeaf(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, identity, issuer string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate key %v", err)
+	}
+	uri, err := url.Parse(identity)
+	if err != nil {
+		t.Fatalf("Unable to parse identity %v", err)
+	}
+	issuerValue, err := asn1.Marshal(issuer)
+	if err != nil {
+		t.Fatalf("Unable to marshal issuer %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-5 * time.Minute),
+		NotAfter:        time.Now().Add(5 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{uri},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuerValue}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Unable to create certificate %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Unable to parse certificate %v", err)
+	}
+	return cert, key
+}
+
+// signBlob signs like cosign sign-blob, ecdsa over the sha256 of the data
+func signBlob(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	sum := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatalf("Unable to sign %v", err)
+	}
+	return signature
+}
+
+func pemCert(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// testLogID the log ID is the sha256 of the public key of the log
+func testLogID(t *testing.T, logKey *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(&logKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Unable to marshal public key %v", err)
+	}
+	logID := sha256.Sum256(der)
+	return logID[:]
+}
+
+// writeTestTrustRoot writes a trusted_root.json with the certificate authority and the transparency log at baseURL
+func writeTestTrustRoot(t *testing.T, location, baseURL string, ca *x509.Certificate, logKey *ecdsa.PrivateKey) {
+	der, err := x509.MarshalPKIXPublicKey(&logKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Unable to marshal public key %v", err)
+	}
+	root, _ := json.Marshal(map[string]interface{}{
+		"mediaType": "application/vnd.dev.sigstore.trustedroot+json;version=0.1",
+		"tlogs": []map[string]interface{}{{
+			"baseUrl":       baseURL,
+			"hashAlgorithm": "SHA2_256",
+			"publicKey": map[string]interface{}{
+				"rawBytes":   der,
+				"keyDetails": "PKIX_ECDSA_P256_SHA_256",
+				"validFor":   map[string]string{"start": time.Now().Add(-time.Hour).Format(time.RFC3339)},
+			},
+			"logId": map[string]interface{}{"keyId": testLogID(t, logKey)},
+		}},
+		"certificateAuthorities": []map[string]interface{}{{
+			"certChain": map[string]interface{}{"certificates": []map[string]interface{}{{"rawBytes": ca.Raw}}},
+		}},
+	})
+	if err := ioutil.WriteFile(location, root, 0644); err != nil {
+		t.Fatalf("Unable to write trust root %v", err)
+	}
+}
+
+// newTestTlogEntry returns a log entry with the body, signed like Rekor signs the canonical JSON of the entry
+func newTestTlogEntry(t *testing.T, logKey *ecdsa.PrivateKey, body interface{}) tlogEntry {
+	canonicalized, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Unable to marshal body %v", err)
+	}
+	entry := tlogEntry{logIndex: 42, logID: testLogID(t, logKey), integratedTime: time.Now().Unix(), body: canonicalized}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"body":           base64.StdEncoding.EncodeToString(entry.body),
+		"integratedTime": entry.integratedTime,
+		"logID":          hex.EncodeToString(entry.logID),
+		"logIndex":       entry.logIndex,
+	})
+	entry.signedEntryTimestamp = signBlob(t, logKey, payload)
+	return entry
+}
+
+// hashedrekordBody the log entry of a signed blob
+func hashedrekordBody(data, signature []byte, cert *x509.Certificate) interface{} {
+	sum := sha256.Sum256(data)
+	return map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"data":      map[string]interface{}{"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(sum[:])}},
+			"signature": map[string]interface{}{"content": signature, "publicKey": map[string]interface{}{"content": pemCert(cert)}},
+		},
+	}
+}
+
+// sigstoreTlogEntry the entry like it's written in a sigstore bundle
+func sigstoreTlogEntry(entry tlogEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"logIndex":          strconv.FormatInt(entry.logIndex, 10),
+		"logId":             map[string]interface{}{"keyId": entry.logID},
+		"integratedTime":    strconv.FormatInt(entry.integratedTime, 10),
+		"inclusionPromise":  map[string]interface{}{"signedEntryTimestamp": entry.signedEntryTimestamp},
+		"canonicalizedBody": entry.body,
+	}
+}
+
+func TestVerifyCosign(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	viper.Set(config.DefaultHTTPtimeoutkey, 5)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024*1024)
+	folder := newTestSaveLocation(t, "testCosign")
+
+	data := []byte("the asset")
+	identity := "https://github.com/tektoncd/cli/.github/workflows/release.yaml@refs/tags/v0.15.0"
+	issuer := "https://token.actions.githubusercontent.com"
+
+	ca, caKey := newTestCA(t, "sigstore")
+	leaf, leafKey := newTestLeaf(t, ca, caKey, identity, issuer)
+	otherCA, otherCAKey := newTestCA(t, "other")
+	untrusted, untrustedKey := newTestLeaf(t, otherCA, otherCAKey, identity, issuer)
+	_, logKey := newTestCA(t, "rekor")
+	_, otherLogKey := newTestCA(t, "other rekor")
+
+	trustRoot := filepath.Join(folder, "trusted_root.json")
+	writeTestTrustRoot(t, trustRoot, "https://rekor.example.com", ca, logKey)
+	pemTrustRoot := filepath.Join(folder, "fulcio.pem")
+	if err := ioutil.WriteFile(pemTrustRoot, pemCert(ca), 0644); err != nil {
+		t.Fatalf("Unable to write trust root %v", err)
+	}
+	keyFile := filepath.Join(folder, "cosign.pub")
+	publicKey, err := x509.MarshalPKIXPublicKey(&leafKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Unable to marshal public key %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}), 0644); err != nil {
+		t.Fatalf("Unable to write key %v", err)
+	}
+
+	rawSignature := signBlob(t, leafKey, data)
+	signature := base64.StdEncoding.EncodeToString(rawSignature)
+	certificate := base64.StdEncoding.EncodeToString(pemCert(leaf))
+	digest := sha256.Sum256(data)
+	newBundle := func(cert *x509.Certificate, signature []byte, entries ...tlogEntry) string {
+		tlogEntries := []map[string]interface{}{}
+		for _, entry := range entries {
+			tlogEntries = append(tlogEntries, sigstoreTlogEntry(entry))
+		}
+		bundle, _ := json.Marshal(map[string]interface{}{
+			"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json",
+			"verificationMaterial": map[string]interface{}{
+				"certificate": map[string]interface{}{"rawBytes": cert.Raw},
+				"tlogEntries": tlogEntries,
+			},
+			"messageSignature": map[string]interface{}{
+				"messageDigest": map[string]interface{}{"algorithm": "SHA2_256", "digest": digest[:]},
+				"signature":     signature,
+			},
+		})
+		return string(bundle)
+	}
+	entry := newTestTlogEntry(t, logKey, hashedrekordBody(data, rawSignature, leaf))
+	sigstore := newBundle(leaf, rawSignature, entry)
+	// the integratedTime is changed after the entry is signed
+	backdated := entry
+	backdated.integratedTime -= 3600
+	// a valid entry, but for another signature
+	otherSignature := signBlob(t, leafKey, data)
+	untrustedSignature := signBlob(t, untrustedKey, data)
+
+	legacy, _ := json.Marshal(map[string]interface{}{
+		"base64Signature": signature,
+		"cert":            certificate,
+		"rekorBundle": map[string]interface{}{
+			"SignedEntryTimestamp": entry.signedEntryTimestamp,
+			"Payload": map[string]interface{}{
+				"body":           base64.StdEncoding.EncodeToString(entry.body),
+				"integratedTime": entry.integratedTime,
+				"logIndex":       entry.logIndex,
+				"logID":          hex.EncodeToString(entry.logID),
+			},
+		},
+	})
+	legacyWithoutLog, _ := json.Marshal(cosignBundle{Base64Signature: signature, Cert: certificate})
+
+	var files map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content)) // #nosec G104
+	}))
+	defer server.Close()
+
+	keyless := config.Cosign{Identity: identity, Issuer: issuer, TrustRoot: trustRoot}
+
+	tests := []struct {
+		name      string
+		cosign    config.Cosign
+		files     map[string]string
+		data      []byte
+		expectErr bool
+	}{
+		{name: "key", cosign: config.Cosign{Key: keyFile}, files: map[string]string{"/tool.tar.gz.sig": signature}},
+		{name: "key modified asset", cosign: config.Cosign{Key: keyFile}, files: map[string]string{"/tool.tar.gz.sig": signature}, data: []byte("modified"), expectErr: true},
+		{name: "sigstore bundle", cosign: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": sigstore}},
+		{name: "sigstore bundle modified asset", cosign: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": sigstore}, data: []byte("modified"), expectErr: true},
+		{name: "cosign bundle", cosign: keyless, files: map[string]string{"/tool.tar.gz.bundle": string(legacy)}},
+		{name: "identityRegexp", cosign: config.Cosign{IdentityRegexp: `^https://github\.com/tektoncd/cli/`, Issuer: issuer, TrustRoot: trustRoot}, files: map[string]string{"/tool.tar.gz.sigstore.json": sigstore}},
+		{name: "wrong identity", cosign: config.Cosign{Identity: "someone@example.com", Issuer: issuer, TrustRoot: trustRoot}, files: map[string]string{"/tool.tar.gz.sigstore.json": sigstore}, expectErr: true},
+		{name: "wrong issuer", cosign: config.Cosign{Identity: identity, Issuer: "https://accounts.google.com", TrustRoot: trustRoot}, files: map[string]string{"/tool.tar.gz.sigstore.json": sigstore}, expectErr: true},
+		{name: "untrusted", cosign: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": newBundle(untrusted, untrustedSignature, newTestTlogEntry(t, logKey, hashedrekordBody(data, untrustedSignature, untrusted)))}, expectErr: true},
+		{name: "signature and certificate without log entry", cosign: keyless, files: map[string]string{"/tool.tar.gz.sig": signature, "/tool.tar.gz.pem": certificate}, expectErr: true},
+		{name: "cosign bundle without log entry", cosign: keyless, files: map[string]string{"/tool.tar.gz.bundle": string(legacyWithoutLog)}, expectErr: true},
+		{name: "sigstore bundle without log entry", cosign: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": newBundle(leaf, rawSignature)}, expectErr: true},
+		{name: "backdated log entry", cosign: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": newBundle(leaf, rawSignature, backdated)}, expectErr: true},
+		{name: "log entry from an unknown log", cosign: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": newBundle(leaf, rawSignature, newTestTlogEntry(t, otherLogKey, hashedrekordBody(data, rawSignature, leaf)))}, expectErr: true},
+		{name: "log entry for another signature", cosign: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": newBundle(leaf, rawSignature, newTestTlogEntry(t, logKey, hashedrekordBody(data, otherSignature, leaf)))}, expectErr: true},
+		{name: "pem trust root", cosign: config.Cosign{Identity: identity, Issuer: issuer, TrustRoot: pemTrustRoot}, files: map[string]string{"/tool.tar.gz.sigstore.json": sigstore}, expectErr: true},
+		{name: "missing signature", cosign: keyless, files: map[string]string{"/tool.tar.gz.pem": certificate}, expectErr: true},
+		{name: "missing certificate", cosign: keyless, files: map[string]string{"/tool.tar.gz.sig": signature}, expectErr: true},
+		{name: "key and identity", cosign: config.Cosign{Key: keyFile, Identity: identity}, files: map[string]string{"/tool.tar.gz.sig": signature}, expectErr: true},
+		{name: "no issuer", cosign: config.Cosign{Identity: identity, TrustRoot: trustRoot}, files: map[string]string{"/tool.tar.gz.sig": signature}, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		files = tests.files
+		cosign := tests.cosign
+		binConfig := config.Bin{Cli: "tool", Verify: &config.Verify{Cosign: &cosign}}
+		entry := lockEntry{AssetName: "tool.tar.gz", DownloadURL: server.URL + "/tool.tar.gz"}
+		verifyData := data
+		if tests.data != nil {
+			verifyData = tests.data
+		}
+		err := verifyCosign(ctx, nil, server.Client(), binConfig, entry, bytesBlob(verifyData))
+		if tests.expectErr {
+			assert.Error(t, err, tests.name)
+			continue
+		}
+		assert.NoError(t, err, tests.name)
+	}
+}
+
+// a blob is signed with Ed25519ph so a download can be streamed, a message in memory like a DSSE envelope with plain ed25519
+func TestVerifyEd25519Signature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate key %v", err)
+	}
+	data := []byte("the asset")
+	digest := sha512.Sum512(data)
+	prehashed, err := privateKey.Sign(rand.Reader, digest[:], &ed25519.Options{Hash: crypto.SHA512})
+	if err != nil {
+		t.Fatalf("Unable to sign %v", err)
+	}
+	pure := ed25519.Sign(privateKey, data)
+
+	assert.NoError(t, verifyBlobSignature(publicKey, bytesBlob(data), prehashed))
+	assert.Error(t, verifyBlobSignature(publicKey, bytesBlob(data), pure))
+	assert.Error(t, verifyBlobSignature(publicKey, bytesBlob("modified"), prehashed))
+	assert.NoError(t, verifySignature(publicKey, data, pure))
+	assert.Error(t, verifySignature(publicKey, data, prehashed))
+}
+
+func TestFindReleaseFile(t *testing.T) {
+	release := &github.RepositoryRelease{TagName: github.String("v1.0.0")}
+	for i, name := range []string{"tool.tar.gz", "tool.tar.gz.sig", "tool.tar.gz.pem", "tool.zip.sig", "cosign.bundle"} {
+		release.Assets = append(release.Assets, &github.ReleaseAsset{ID: github.Int64(int64(i + 1)), Name: github.String(name)})
+	}
+
+	tests := []struct {
+		pattern   string
+		suffixes  []string
+		expectOut string
+		expectErr bool
+	}{
+		{suffixes: cosignSignatureSuffixes, expectOut: "tool.tar.gz.sig"},
+		{suffixes: cosignCertificateSuffixes, expectOut: "tool.tar.gz.pem"},
+		{suffixes: cosignBundleSuffixes, expectOut: ""},
+		{pattern: `\.bundle$`, suffixes: cosignBundleSuffixes, expectOut: "cosign.bundle"},
+		{pattern: `\.minisig$`, expectErr: true},
+		{pattern: `(`, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		found, err := findReleaseFile("tool", release, "tool.tar.gz", tests.pattern, tests.suffixes)
+		if tests.expectErr {
+			assert.Error(t, err, tests.pattern)
+			continue
+		}
+		assert.NoError(t, err, tests.pattern)
+		if tests.expectOut == "" {
+			assert.Nil(t, found)
+			continue
+		}
+		if assert.NotNil(t, found, tests.expectOut) {
+			assert.Equal(t, tests.expectOut, found.name)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/google/go-github/v33/github"
+	"github.com/spf13/viper"
+)
+
+// downloadPrefix the name of the temp files that downloads is streamed to, they are created in TMPDIR
+const downloadPrefix = "githubbindl-download-"
+
+// blob is content that can be read more than once, a download is read from disk and a small file like a checksum file from memory
+type blob interface {
+	open() (io.ReadCloser, error)
+}
+
+// bytesBlob content that already is in memory
+type bytesBlob []byte
+
+func (b bytesBlob) open() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// download a asset that is streamed to a temp file, the sha256 is calculated while it's downloaded
+type download struct {
+	path   string
+	size   int64
+	sha256 string
+	// header is only set for nonGithubURL downloads
+	header http.Header
+}
+
+func (d *download) open() (io.ReadCloser, error) {
+	return os.Open(d.path)
+}
+
+// remove deletes the temp file
+func (d *download) remove() error {
+	return os.Remove(d.path)
+}
+
+// downloadAsset streams the asset to a temp file, release assets is downloaded through the GitHub API so private repos works.
+// The download is stopped as soon as it's bigger than maxDownloadSize, the size is checked up front when it's known.
+func downloadAsset(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, entry lockEntry) (*download, error) {
+	maxDownloadSize := viper.GetInt64(config.DefaultMaxDownloadSizeKey)
+	// the size is known from the release or the lock file
+	if entry.Size > maxDownloadSize {
+		return nil, fmt.Errorf("%v: %v is %v which is bigger than allowed maxDownloadSize %v byte", binConfig.Cli, entry.AssetName, entry.Size, maxDownloadSize)
+	}
+
+	var body io.ReadCloser
+	var header http.Header
+	if entry.AssetID != 0 {
+		rc, _, err := client.Repositories.DownloadReleaseAsset(ctx, binConfig.Owner, binConfig.Repo, entry.AssetID, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		body = rc
+	} else {
+		resp, cancel, err := getURL(ctx, httpClient, binConfig.Cli, entry.DownloadURL)
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		if resp.ContentLength > maxDownloadSize {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("%v: %v is %v which is bigger than allowed maxDownloadSize %v byte", binConfig.Cli, entry.AssetName, resp.ContentLength, maxDownloadSize)
+		}
+		body = resp.Body
+		header = resp.Header
+	}
+	defer body.Close()
+
+	sum := sha256.New()
+	f, size, err := spool(body, binConfig.Cli, sum)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	return &download{path: f.Name(), size: size, sha256: hex.EncodeToString(sum.Sum(nil)), header: header}, nil
+}
+
+// spool copies r to a temp file, and to the writers, and stops when more than maxDownloadSize is read.
+// The caller have to close and remove the file.
+func spool(r io.Reader, cliName string, writers ...io.Writer) (*os.File, int64, error) {
+	f, err := ioutil.TempFile("", downloadPrefix)
+	if err != nil {
+		return nil, 0, err
+	}
+	maxDownloadSize := viper.GetInt64(config.DefaultMaxDownloadSizeKey)
+	limited := &maxSizeReader{r: r, name: cliName, setting: config.DefaultMaxDownloadSizeKey, left: maxDownloadSize, max: maxDownloadSize}
+	size, err := io.Copy(io.MultiWriter(append([]io.Writer{f}, writers...)...), limited)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, 0, err
+	}
+	return f, size, nil
+}
+
+// getURL sends a GET request, anything but a 2xx status is an error.
+// The caller have to close the body and call cancel when it's done with it.
+func getURL(ctx context.Context, httpClient *http.Client, cliName, downloadURL string) (*http.Response, context.CancelFunc, error) {
+	req, err := http.NewRequest(http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sendRequest(ctx, httpClient, cliName, req)
+}
+
+// sendRequest sends the request with the http timeout, anything but a 2xx status is an error.
+// The caller have to close the body and call cancel when it's done with it.
+func sendRequest(ctx context.Context, httpClient *http.Client, cliName string, req *http.Request) (*http.Response, context.CancelFunc, error) {
+	// Instead of using httpClient.Timeout I use a ctx with Deadline.
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(time.Duration(viper.GetInt(config.DefaultHTTPtimeoutkey))*time.Second))
+	downloadURL := req.URL.String()
+	req = req.WithContext(ctx)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_ = resp.Body.Close()
+		cancel()
+		return nil, nil, fmt.Errorf("%v: unable to download %v, got status %v", cliName, downloadURL, resp.Status)
+	}
+	return resp, cancel, nil
+}
+
+// blobDigest streams the blob through the hash
+func blobDigest(b blob, h hash.Hash) ([]byte, error) {
+	rc, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	if _, err := io.Copy(h, rc); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// blobSHA256 returns the sha256 of the blob, a download already knows it
+func blobSHA256(b blob) ([]byte, error) {
+	if d, ok := b.(*download); ok {
+		return hex.DecodeString(d.sha256)
+	}
+	return blobDigest(b, sha256.New())
+}
+
+// readBlob reads the whole blob, only used where the content itself is signed like legacy minisign signatures
+func readBlob(b blob) ([]byte, error) {
+	rc, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
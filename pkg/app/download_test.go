@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// the download ends up in a temp file with the right sha256 and stops as soon as it's bigger than maxDownloadSize
+func TestDownloadAsset(t *testing.T) {
+	small := "#!/bin/sh\necho tool\n"
+	big := strings.Repeat("x", 2048)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			_, _ = w.Write([]byte(small))
+		case "/big":
+			_, _ = w.Write([]byte(big))
+		case "/streamed":
+			// flushing before everything is written gives a chunked response without Content-Length
+			_, _ = w.Write([]byte(big[:512]))
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(big[512:]))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	defer viper.Reset()
+	viper.Set(config.DefaultHTTPtimeoutkey, 5)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024)
+
+	sum := sha256.Sum256([]byte(small))
+
+	tests := []struct {
+		name      string
+		path      string
+		size      int64
+		expectErr bool
+	}{
+		{name: "fits", path: "/small"},
+		{name: "content length", path: "/big", expectErr: true},
+		{name: "streamed", path: "/streamed", expectErr: true},
+		{name: "known size", path: "/small", size: 4096, expectErr: true},
+		{name: "not found", path: "/missing", expectErr: true},
+	}
+
+	for _, tests := range tests {
+		entry := lockEntry{AssetName: "tool", DownloadURL: server.URL + tests.path, Size: tests.size}
+		asset, err := downloadAsset(context.Background(), nil, server.Client(), config.Bin{Cli: "tool"}, entry)
+		if tests.expectErr {
+			assert.Error(t, err, tests.name)
+			continue
+		}
+		if !assert.NoError(t, err, tests.name) {
+			continue
+		}
+		content, err := ioutil.ReadFile(asset.path)
+		assert.NoError(t, err, tests.name)
+		assert.Equal(t, small, string(content), tests.name)
+		assert.Equal(t, int64(len(small)), asset.size, tests.name)
+		assert.Equal(t, hex.EncodeToString(sum[:]), asset.sha256, tests.name)
+
+		assert.NoError(t, asset.remove(), tests.name)
+		_, err = os.Stat(asset.path)
+		assert.True(t, os.IsNotExist(err), tests.name)
+	}
+}
+
+// a zip on disk is read in place and a zip from any other reader is spooled to disk first
+func TestUnZIPFromFile(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+
+	archive := createZIP(t, []archiveFile{{name: "dist/tool", content: "tool"}})
+	folder := newTestSaveLocation(t, "testUnZIP")
+	location := filepath.Join(folder, "tool.zip")
+	if err := ioutil.WriteFile(location, archive, 0644); err != nil {
+		t.Fatalf("Unable to write zip %v", err)
+	}
+	f, err := os.Open(location)
+	if err != nil {
+		t.Fatalf("Unable to open zip %v", err)
+	}
+	defer f.Close()
+
+	dst := newTestSaveLocation(t, "testUnZIP")
+	assert.NoError(t, pickExtension(ctx, f, config.Bin{Cli: "tool"}, dst, "tool.zip", nil, false))
+	output, err := ioutil.ReadFile(filepath.Join(dst, "tool"))
+	assert.NoError(t, err)
+	assert.Equal(t, "tool", string(output))
+
+	// spooling is limited by maxDownloadSize
+	spooled := newTestSaveLocation(t, "testUnZIP")
+	viper.Set(config.DefaultMaxDownloadSizeKey, 16)
+	assert.Error(t, pickExtension(ctx, ioutil.NopCloser(strings.NewReader(string(archive))), config.Bin{Cli: "tool"}, spooled, "tool.zip", nil, false))
+}
@@ -0,0 +1,248 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+)
+
+// defaultFileMode is used for everything that is extracted unless the file have a mode
+const defaultFileMode = os.FileMode(0755)
+
+// stagePrefix downloads is unpacked in a temporary folder with this prefix inside the destination before they are moved in place
+const stagePrefix = ".githubbindl-stage-"
+
+// extractRule decides which archive members to extract and what to call them
+type extractRule struct {
+	// description is used in errors when nothing matched
+	description string
+	match       func(memberPath string) bool
+	// name is the installed name, if empty the base name of the member is used
+	name string
+	mode os.FileMode
+	// dir is the folder inside dst, dirFunc is used instead if the folder depends on the member
+	dir     string
+	dirFunc func(memberPath string) string
+	// optional rules is allowed to not match anything, used by installLayout
+	optional bool
+	// found is the number of members that matched
+	found int
+}
+
+// target returns where the member should be written
+func (r *extractRule) target(dst, memberPath string) string {
+	dir := r.dir
+	if r.dirFunc != nil {
+		dir = r.dirFunc(memberPath)
+	}
+	if r.name != "" {
+		return filepath.Join(dst, dir, r.name)
+	}
+	return filepath.Join(dst, dir, path.Base(memberPath))
+}
+
+// parseMode parses a octal file mode, fallback is used if the mode is empty
+func parseMode(cliName, mode string, fallback os.FileMode) (os.FileMode, error) {
+	if mode == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%v: invalid mode %v: %v", cliName, mode, err)
+	}
+	return os.FileMode(parsed), nil
+}
+
+// extractRules returns the rules for a bin, the cli is always extracted and files adds more.
+// With layout the cli and files is installed in dst/bin and the layout rules is added last.
+func extractRules(binConfig config.Bin, layout bool) ([]*extractRule, error) {
+	binDir := ""
+	if layout {
+		binDir = layoutBinDir
+	}
+	cliName := binConfig.Cli
+	cliRule := &extractRule{
+		description: cliName,
+		/* HELM is a pain, the bin file is inside a folder.
+		Only the base name is compared with the cli unless archivePath is set.
+		*/
+		match: func(memberPath string) bool { return path.Base(memberPath) == cliName },
+		name:  binConfig.InstallName(),
+		mode:  defaultFileMode,
+		dir:   binDir,
+	}
+	if binConfig.ArchivePath != "" {
+		match, err := globMatcher(binConfig.ArchivePath)
+		if err != nil {
+			return nil, fmt.Errorf("%v: invalid archivePath %q: %v", binConfig.Cli, binConfig.ArchivePath, err)
+		}
+		cliRule.description = binConfig.ArchivePath
+		cliRule.match = match
+	}
+	rules := []*extractRule{cliRule}
+
+	for _, file := range binConfig.Files {
+		mode, err := parseMode(binConfig.Cli, file.Mode, defaultFileMode)
+		if err != nil {
+			return nil, err
+		}
+		rule := &extractRule{name: file.Name, mode: mode, dir: binDir}
+
+		switch {
+		case file.Path != "" && file.Match != "":
+			return nil, fmt.Errorf("%v: a file can only have one of path and match", binConfig.Cli)
+		case file.Path != "":
+			match, err := globMatcher(file.Path)
+			if err != nil {
+				return nil, fmt.Errorf("%v: invalid files path %q: %v", binConfig.Cli, file.Path, err)
+			}
+			rule.description = file.Path
+			rule.match = match
+		case file.Match != "":
+			r, err := regexp.Compile(file.Match)
+			if err != nil {
+				return nil, fmt.Errorf("%v: invalid files match %q: %v", binConfig.Cli, file.Match, err)
+			}
+			rule.description = file.Match
+			rule.match = r.MatchString
+		default:
+			return nil, fmt.Errorf("%v: a file needs path or match", binConfig.Cli)
+		}
+		rules = append(rules, rule)
+	}
+
+	if layout {
+		extra, err := layoutRules(binConfig)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, extra...)
+	}
+	return rules, nil
+}
+
+// cleanMemberPath makes paths from tar and zip comparable, ./bin/tkn and bin/tkn is the same file
+func cleanMemberPath(memberPath string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(memberPath)), "/")
+}
+
+// globMatcher matches the whole path inside the archive, the pattern can be a exact path or a glob like */helm
+func globMatcher(pattern string) (func(memberPath string) bool, error) {
+	pattern = cleanMemberPath(pattern)
+	// validate the pattern once so the errors can be ignored when matching
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return func(memberPath string) bool {
+		matched, _ := path.Match(pattern, memberPath)
+		return matched
+	}, nil
+}
+
+// matchRule returns the first rule that matches the member or nil
+func matchRule(rules []*extractRule, memberPath string) *extractRule {
+	for _, rule := range rules {
+		if rule.match(memberPath) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// checkRules returns a error if any rule didn't find anything in the archive
+// or if a rule with a install name found more than one file, they would overwrite each other.
+func checkRules(rules []*extractRule) error {
+	var missing []string
+	for _, rule := range rules {
+		if rule.optional {
+			continue
+		}
+		if rule.found == 0 {
+			missing = append(missing, rule.description)
+		}
+		if rule.found > 1 && rule.name != "" {
+			return fmt.Errorf("%v matches %v files in the archive, use archivePath or path to pick one of them", rule.description, rule.found)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("unable to find %v in the archive", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// writeFile writes the content to target with mode, target have to be inside dst
+func writeFile(dst, target string, mode os.FileMode, r io.Reader) error {
+	// Check for ZipSlip. More Info: http://bit.ly/2MsjAWE
+	if !strings.HasPrefix(target, filepath.Clean(dst)+string(os.PathSeparator)) {
+		return fmt.Errorf("%s: illegal file path", target)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode) // #nosec G304
+	if err != nil {
+		return err
+	}
+
+	// copy over contents
+	/* #nosec G110*/
+	if _, err := io.Copy(file, r); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	// manually close here after each file operation; defering would cause each file close
+	// to wait until all operations have completed.
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// OpenFile only sets the mode when the file is created
+	return os.Chmod(target, mode)
+}
+
+// moveStaged moves every file in stage to the same path in dst.
+// stage is in dst so it's a rename on the same filesystem and a running cli is never half written.
+func moveStaged(stage, dst string) error {
+	return filepath.Walk(stage, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(stage, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Rename(p, target)
+	})
+}
+
+// maxSizeReader returns a error when more than max bytes is read, used for downloads and single compressed files where the size is unknown until it's read.
+// setting is the config key of the limit, like maxFileSize.
+type maxSizeReader struct {
+	r       io.Reader
+	name    string
+	setting string
+	left    int64
+	max     int64
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	m.left -= int64(n)
+	if m.left < 0 {
+		return n, fmt.Errorf("%v: is bigger than allowed %v %v byte", m.name, m.setting, m.max)
+	}
+	return n, err
+}
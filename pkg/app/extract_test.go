@@ -0,0 +1,191 @@
+package app
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// archiveFile a file in a test archive
+type archiveFile struct {
+	name    string
+	content string
+}
+
+// createTar returns a uncompressed tar containing the files
+func createTar(t *testing.T, files []archiveFile) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("Unable to write tar header %v", err)
+		}
+		if _, err := tw.Write([]byte(file.content)); err != nil {
+			t.Fatalf("Unable to write tar content %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Unable to close tar %v", err)
+	}
+	return buf.Bytes()
+}
+
+// createTarGZ returns a tar.gz containing the files
+func createTarGZ(t *testing.T, files []archiveFile) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(createTar(t, files)); err != nil {
+		t.Fatalf("Unable to write gzip %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("Unable to close gzip %v", err)
+	}
+	return buf.Bytes()
+}
+
+// createZIP returns a zip containing the files
+func createZIP(t *testing.T, files []archiveFile) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			t.Fatalf("Unable to create zip member %v", err)
+		}
+		if _, err := w.Write([]byte(file.content)); err != nil {
+			t.Fatalf("Unable to write zip member %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Unable to close zip %v", err)
+	}
+	return buf.Bytes()
+}
+
+// newTestSaveLocation creates a tmp folder and sets the viper values the extractors needs
+func newTestSaveLocation(t *testing.T, prefix string) string {
+	workspace := getEnv("TEMP_DIR", "/tmp")
+	folder, err := ioutil.TempDir(workspace, prefix)
+	if err != nil {
+		t.Fatalf("Unable to create a tmp dir %v", err)
+	}
+	viper.Set(config.DefaultSaveLocationKey, folder)
+	viper.Set(config.DefaultMaxFileSizeKey, 1024)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024*1024)
+	return folder
+}
+
+func TestExtractFiles(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+
+	files := []archiveFile{
+		{name: "./dist/tool", content: "tool"},
+		{name: "dist/tool-daemon", content: "daemon"},
+		{name: "plugins/kubectl-foo", content: "foo"},
+		{name: "plugins/kubectl-bar", content: "bar"},
+		{name: "README.md", content: "readme"},
+	}
+	bin := config.Bin{
+		Cli: "tool",
+		Files: []config.File{
+			{Path: "dist/tool-daemon", Name: "toold", Mode: "0750"},
+			{Match: `^plugins/kubectl-`},
+		},
+	}
+
+	archives := map[string][]byte{
+		"tool.tar.gz": createTarGZ(t, files),
+		"tool.zip":    createZIP(t, files),
+	}
+
+	for name, archive := range archives {
+		folder := newTestSaveLocation(t, "testExtract")
+		err := pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(archive)), bin, folder, name, nil, false)
+		if err != nil {
+			t.Errorf("Unable to extract %v, err: %v", name, err)
+			continue
+		}
+
+		for installed, content := range map[string]string{"tool": "tool", "toold": "daemon", "kubectl-foo": "foo", "kubectl-bar": "bar"} {
+			output, err := ioutil.ReadFile(filepath.Join(folder, installed))
+			assert.NoError(t, err, name)
+			assert.Equal(t, content, string(output), name)
+		}
+		stat, err := os.Stat(filepath.Join(folder, "toold"))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0750), stat.Mode().Perm(), name)
+
+		_, err = os.Stat(filepath.Join(folder, "README.md"))
+		assert.True(t, os.IsNotExist(err), name)
+
+		// a file that isn't in the archive
+		missing := bin
+		missing.Files = append(missing.Files, config.File{Path: "dist/missing"})
+		assert.Error(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(archive)), missing, folder, name, nil, false), name)
+	}
+
+	// a install name can't leave saveLocation
+	folder := newTestSaveLocation(t, "testExtract")
+	escape := config.Bin{Cli: "tool", Files: []config.File{{Path: "README.md", Name: "../README.md"}}}
+	assert.Error(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(archives["tool.tar.gz"])), escape, folder, "tool.tar.gz", nil, false))
+
+	_, err := extractRules(config.Bin{Cli: "tool", Files: []config.File{{Path: "a", Match: "b"}}}, false)
+	assert.Error(t, err)
+	_, err = extractRules(config.Bin{Cli: "tool", Files: []config.File{{Path: "a", Mode: "rwx"}}}, false)
+	assert.Error(t, err)
+}
+
+func TestExtractArchivePath(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+
+	archive := createTarGZ(t, []archiveFile{
+		{name: "linux-amd64/helm", content: "helm3"},
+		{name: "legacy/helm", content: "helm2"},
+	})
+
+	tests := []struct {
+		bin       config.Bin
+		installed string
+		expectOut string
+		expectErr bool
+	}{
+		{bin: config.Bin{Cli: "helm", ArchivePath: "linux-amd64/helm", InstallAs: "helm3"}, installed: "helm3", expectOut: "helm3"},
+		{bin: config.Bin{Cli: "helm", ArchivePath: "./legacy/helm"}, installed: "helm", expectOut: "helm2"},
+		{bin: config.Bin{Cli: "helm", ArchivePath: "linux-*/helm", InstallAs: "helm3"}, installed: "helm3", expectOut: "helm3"},
+		// two files with the same name would overwrite each other
+		{bin: config.Bin{Cli: "helm"}, expectErr: true},
+		{bin: config.Bin{Cli: "helm", ArchivePath: "*/helm"}, expectErr: true},
+		{bin: config.Bin{Cli: "helm", ArchivePath: "[/helm"}, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		folder := newTestSaveLocation(t, "testArchivePath")
+		err := pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(archive)), tests.bin, folder, "helm.tar.gz", nil, false)
+		if tests.expectErr {
+			assert.Error(t, err, tests.bin.ArchivePath)
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unable to extract %v, err: %v", tests.bin.ArchivePath, err)
+			continue
+		}
+		output, err := ioutil.ReadFile(filepath.Join(folder, tests.installed))
+		assert.NoError(t, err)
+		assert.Equal(t, tests.expectOut, string(output), tests.bin.ArchivePath)
+	}
+}
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// formats a download can have, set with format on the bin or detected by detectFormat.
+// gz, xz, bz2 and zst is unpacked as a tar if the content is a tar, else as a single file. tar.gz etc. is always a tar.
+const (
+	formatGZ       = "gz"
+	formatXZ       = "xz"
+	formatBZIP2    = "bz2"
+	formatZSTD     = "zst"
+	formatTar      = "tar"
+	formatTarGZ    = "tar.gz"
+	formatTarXZ    = "tar.xz"
+	formatTarBZIP2 = "tar.bz2"
+	formatTarZSTD  = "tar.zst"
+	formatZIP      = "zip"
+	formatBinary   = "binary"
+)
+
+// sniffLength how many bytes detectFormat needs from the start of the download, a tar header is 512 bytes
+const sniffLength = 512
+
+// compressions the compression of each format, formats without compression is not in the map
+var compressions = map[string]string{
+	formatGZ:       formatGZ,
+	formatXZ:       formatXZ,
+	formatBZIP2:    formatBZIP2,
+	formatZSTD:     formatZSTD,
+	formatTarGZ:    formatGZ,
+	formatTarXZ:    formatXZ,
+	formatTarBZIP2: formatBZIP2,
+	formatTarZSTD:  formatZSTD,
+}
+
+// magicNumbers the first bytes of the formats, executables and scripts are all installed as a binary
+var magicNumbers = []struct {
+	magic  []byte
+	format string
+}{
+	{magic: []byte{0x1f, 0x8b}, format: formatGZ},
+	{magic: []byte("PK\x03\x04"), format: formatZIP},
+	{magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, format: formatXZ},
+	{magic: []byte("BZh"), format: formatBZIP2},
+	{magic: []byte{0x28, 0xb5, 0x2f, 0xfd}, format: formatZSTD},
+	{magic: arMagic, format: formatDeb},
+	{magic: rpmLeadMagic, format: formatRPM},
+	// ELF
+	{magic: []byte{0x7f, 'E', 'L', 'F'}, format: formatBinary},
+	// PE, windows exe
+	{magic: []byte("MZ"), format: formatBinary},
+	// Mach-O 32 and 64 bit in both byte orders and universal binaries
+	{magic: []byte{0xfe, 0xed, 0xfa, 0xce}, format: formatBinary},
+	{magic: []byte{0xfe, 0xed, 0xfa, 0xcf}, format: formatBinary},
+	{magic: []byte{0xce, 0xfa, 0xed, 0xfe}, format: formatBinary},
+	{magic: []byte{0xcf, 0xfa, 0xed, 0xfe}, format: formatBinary},
+	{magic: []byte{0xca, 0xfe, 0xba, 0xbe}, format: formatBinary},
+	// scripts
+	{magic: []byte("#!"), format: formatBinary},
+}
+
+// contentTypes maps Content-Type headers to formats, application/octet-stream says nothing and is ignored
+var contentTypes = map[string]string{
+	"application/gzip":                      formatGZ,
+	"application/x-gzip":                    formatGZ,
+	"application/x-gtar":                    formatTarGZ,
+	"application/x-tgz":                     formatTarGZ,
+	"application/x-tar":                     formatTar,
+	"application/zip":                       formatZIP,
+	"application/x-zip-compressed":          formatZIP,
+	"application/x-xz":                      formatXZ,
+	"application/x-bzip2":                   formatBZIP2,
+	"application/zstd":                      formatZSTD,
+	"application/x-executable":              formatBinary,
+	"application/x-elf":                     formatBinary,
+	"application/x-msdownload":              formatBinary,
+	"application/x-mach-binary":             formatBinary,
+	"application/vnd.debian.binary-package": formatDeb,
+	"application/x-debian-package":          formatDeb,
+	"application/x-rpm":                     formatRPM,
+}
+
+// nameSuffixes maps the end of a file name to a format, longer suffixes first
+var nameSuffixes = []struct {
+	suffix string
+	format string
+}{
+	{suffix: ".tar.gz", format: formatTarGZ},
+	{suffix: ".tgz", format: formatTarGZ},
+	{suffix: ".tar.xz", format: formatTarXZ},
+	{suffix: ".txz", format: formatTarXZ},
+	{suffix: ".tar.bz2", format: formatTarBZIP2},
+	{suffix: ".tbz2", format: formatTarBZIP2},
+	{suffix: ".tbz", format: formatTarBZIP2},
+	{suffix: ".tar.zst", format: formatTarZSTD},
+	{suffix: ".tzst", format: formatTarZSTD},
+	{suffix: ".tar", format: formatTar},
+	{suffix: gzExtension, format: formatGZ},
+	{suffix: ".xz", format: formatXZ},
+	{suffix: ".bz2", format: formatBZIP2},
+	{suffix: ".zst", format: formatZSTD},
+	{suffix: zipExtension, format: formatZIP},
+	{suffix: ".deb", format: formatDeb},
+	{suffix: ".rpm", format: formatRPM},
+	{suffix: exeExtension, format: formatBinary},
+	{suffix: ".appimage", format: formatBinary},
+	{suffix: ".bin", format: formatBinary},
+}
+
+// versionedName matches names that end with a version, like tool-1.2.3, tool-v1.2.3 and tool-1.2.3-linux-amd64, the dots are part of the version and not a extension
+var versionedName = regexp.MustCompile(`[-_]v?\d+(\.\d+)+([-_][0-9a-z_-]*)?$`)
+
+// formatFromName returns the format based on the file name, a name without extension is a binary
+func formatFromName(name string) (string, bool) {
+	name = strings.ToLower(name)
+	for _, s := range nameSuffixes {
+		if strings.HasSuffix(name, s.suffix) {
+			return s.format, true
+		}
+	}
+	if path.Ext(name) == "" || versionedName.MatchString(name) {
+		return formatBinary, true
+	}
+	return "", false
+}
+
+// validFormat returns true if the format can be used as format on a bin
+func validFormat(format string) bool {
+	switch format {
+	case formatTar, formatZIP, formatBinary, formatDeb, formatRPM:
+		return true
+	}
+	_, ok := compressions[format]
+	return ok
+}
+
+// detectFormat finds the format of a download.
+// format from the config always wins, then the magic bytes of the download, the Content-Disposition and Content-Type headers and last the asset name.
+func detectFormat(configFormat string, head []byte, header http.Header, assetName string) (string, error) {
+	if configFormat != "" {
+		if configFormat == "tgz" {
+			return formatTarGZ, nil
+		}
+		if !validFormat(configFormat) {
+			return "", fmt.Errorf("unknown format %q", configFormat)
+		}
+		return configFormat, nil
+	}
+
+	for _, m := range magicNumbers {
+		if bytes.HasPrefix(head, m.magic) {
+			return m.format, nil
+		}
+	}
+	if isTarHeader(head) {
+		return formatTar, nil
+	}
+
+	if disposition := header.Get("Content-Disposition"); disposition != "" {
+		if _, params, err := mime.ParseMediaType(disposition); err == nil && params["filename"] != "" {
+			if format, ok := formatFromName(params["filename"]); ok {
+				return format, nil
+			}
+		}
+	}
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			if format, ok := contentTypes[mediaType]; ok {
+				return format, nil
+			}
+		}
+	}
+
+	if format, ok := formatFromName(assetName); ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("unable to detect the format of %v, set format on the bin", assetName)
+}
+
+// isTarHeader returns true if the bytes starts with a ustar or gnu tar header
+func isTarHeader(head []byte) bool {
+	return len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar"))
+}
+
+// decompress returns a reader of the uncompressed content
+func decompress(compression string, r io.Reader) (io.ReadCloser, error) {
+	switch compression {
+	case formatGZ:
+		return gzip.NewReader(r)
+	case formatXZ:
+		xzr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xzr), nil
+	case formatBZIP2:
+		return ioutil.NopCloser(bzip2.NewReader(r)), nil
+	case formatZSTD:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unknown compression %v", compression)
+}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+func TestDetectFormat(t *testing.T) {
+	elf := []byte{0x7f, 'E', 'L', 'F', 2, 1, 1}
+
+	tests := []struct {
+		configFormat string
+		head         []byte
+		header       http.Header
+		assetName    string
+		expectOut    string
+		expectErr    bool
+	}{
+		{head: []byte{0x1f, 0x8b, 8}, assetName: "download", expectOut: formatGZ},
+		{head: createTar(t, []archiveFile{{name: "tool", content: "tool"}}), assetName: "download", expectOut: formatTar},
+		{head: []byte("PK\x03\x04"), assetName: "tool.tar.gz", expectOut: formatZIP},
+		{head: elf, assetName: "tool-1.2.3", expectOut: formatBinary},
+		{head: []byte("MZ\x90\x00"), assetName: "tool", expectOut: formatBinary},
+		{head: []byte{0xcf, 0xfa, 0xed, 0xfe}, assetName: "tool", expectOut: formatBinary},
+		{head: []byte("#!/bin/sh\n"), assetName: "install.sh", expectOut: formatBinary},
+		{head: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, assetName: "tool", expectOut: formatXZ},
+		{head: []byte("BZh91AY"), assetName: "tool", expectOut: formatBZIP2},
+		{head: []byte{0x28, 0xb5, 0x2f, 0xfd}, assetName: "tool", expectOut: formatZSTD},
+		{head: []byte("text"), header: http.Header{"Content-Disposition": {`attachment; filename="tool_linux.zip"`}}, assetName: "download", expectOut: formatZIP},
+		{head: []byte("text"), header: http.Header{"Content-Type": {"application/x-gzip"}}, assetName: "download.php", expectOut: formatGZ},
+		{head: []byte("text"), assetName: "zig-linux-x86_64-0.7.1.tar.xz", expectOut: formatTarXZ},
+		{head: []byte("text"), assetName: "tool.tbz", expectOut: formatTarBZIP2},
+		{head: []byte("text"), assetName: "tool_linux.zst", expectOut: formatZSTD},
+		{head: []byte("text"), assetName: "tool-1.2.3-linux-amd64", expectOut: formatBinary},
+		{head: []byte("text"), header: http.Header{"Content-Type": {"application/octet-stream"}}, assetName: "tool.AppImage", expectOut: formatBinary},
+		{head: []byte("text"), assetName: "tool-1.2.3", expectOut: formatBinary},
+		{head: []byte("text"), assetName: "tool-v1.2.3", expectOut: formatBinary},
+		{head: []byte("text"), assetName: "tool_v1.2.3_linux_amd64", expectOut: formatBinary},
+		{head: []byte("text"), assetName: "tool-1.2.3.msi", expectErr: true},
+		{head: []byte("text"), assetName: "tool-1.2.3.sha256", expectErr: true},
+		{head: []byte("text"), assetName: "tool.deb", expectOut: formatDeb},
+		{head: []byte("text"), assetName: "tool.msi", expectErr: true},
+		{configFormat: formatZIP, head: elf, assetName: "tool", expectOut: formatZIP},
+		{configFormat: "tgz", head: elf, assetName: "tool", expectOut: formatTarGZ},
+		{configFormat: "rar", head: elf, assetName: "tool", expectErr: true},
+	}
+
+	for _, tests := range tests {
+		format, err := detectFormat(tests.configFormat, tests.head, tests.header, tests.assetName)
+		if tests.expectErr {
+			assert.Error(t, err, tests.assetName)
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unable to detect format of %v, err: %v", tests.assetName, err)
+			continue
+		}
+		assert.Equal(t, tests.expectOut, format, tests.assetName)
+	}
+}
+
+// the format is found from the content so the asset name don't matter
+func TestPickExtensionSniffing(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	folder := newTestSaveLocation(t, "testSniff")
+
+	bin := config.Bin{Cli: "tool"}
+	archive := createTarGZ(t, []archiveFile{{name: "tool", content: "from tar"}})
+	assert.NoError(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(archive)), bin, folder, "tool-1.2.3", nil, false))
+	installed, err := ioutil.ReadFile(filepath.Join(folder, "tool"))
+	assert.NoError(t, err)
+	assert.Equal(t, "from tar", string(installed))
+
+	elf := append([]byte{0x7f, 'E', 'L', 'F'}, []byte("binary")...)
+	assert.NoError(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(elf)), bin, folder, "tool-1.2.3.bin", nil, false))
+	installed, err = ioutil.ReadFile(filepath.Join(folder, "tool"))
+	assert.NoError(t, err)
+	assert.Equal(t, elf, installed)
+
+	// a broken xz stream
+	xz := []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	assert.Error(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(xz)), bin, folder, "tool.tar.xz", nil, false))
+}
+
+// compress returns the data compressed with the compression, bzip2 can't be written with the standard library so it's not supported
+func compress(t *testing.T, compression string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch compression {
+	case formatGZ:
+		w = gzip.NewWriter(&buf)
+	case formatXZ:
+		w, err = xz.NewWriter(&buf)
+	case formatZSTD:
+		w, err = zstd.NewWriter(&buf)
+	default:
+		t.Fatalf("Unknown compression %v", compression)
+	}
+	if err != nil {
+		t.Fatalf("Unable to create %v writer %v", compression, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Unable to write %v %v", compression, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unable to close %v %v", compression, err)
+	}
+	return buf.Bytes()
+}
+
+func TestUnpackFormats(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	folder := newTestSaveLocation(t, "testFormats")
+
+	tarball := createTar(t, []archiveFile{{name: "bin/tool", content: "from tar"}})
+	// created with python, bzip2 of "from bz2" and a tar.bz2 with bin/tool containing "from tar.bz2"
+	bz2File, _ := base64.StdEncoding.DecodeString("QlpoOTFBWSZTWaEmn9kAAAGZgEAAEAARApAQIAAiBpp6EMCONXgQXckU4UJChJp/ZA==")
+	bz2Tar, _ := base64.StdEncoding.DecodeString("QlpoOTFBWSZTWQThmewAAHB7gMqAAQBAAf6AAEBxJ54QCAggAFQyiDQNNBtQD01BJKGmmgNABoH21ZMhBbQhCLuo1EbIPQIYGOH84OE5hGjyH3SpMJm62BUTRvFCu2h8FZko2MiSB+LuSKcKEgCcMz2A")
+
+	tests := []struct {
+		assetName string
+		data      []byte
+		expectOut string
+	}{
+		{assetName: "tool.tar", data: tarball, expectOut: "from tar"},
+		{assetName: "tool.tgz", data: compress(t, formatGZ, tarball), expectOut: "from tar"},
+		{assetName: "tool.tar.xz", data: compress(t, formatXZ, tarball), expectOut: "from tar"},
+		{assetName: "tool.tar.zst", data: compress(t, formatZSTD, tarball), expectOut: "from tar"},
+		{assetName: "tool.tar.bz2", data: bz2Tar, expectOut: "from tar.bz2"},
+		{assetName: "tool_linux.gz", data: compress(t, formatGZ, []byte("from gz")), expectOut: "from gz"},
+		{assetName: "tool_linux.xz", data: compress(t, formatXZ, []byte("from xz")), expectOut: "from xz"},
+		{assetName: "tool_linux.zst", data: compress(t, formatZSTD, []byte("from zst")), expectOut: "from zst"},
+		{assetName: "tool_linux.bz2", data: bz2File, expectOut: "from bz2"},
+		// the content decides if a compressed file is a tar
+		{assetName: "download", data: compress(t, formatXZ, tarball), expectOut: "from tar"},
+	}
+
+	for _, tests := range tests {
+		err := pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(tests.data)), config.Bin{Cli: "tool"}, folder, tests.assetName, nil, false)
+		if err != nil {
+			t.Errorf("Unable to unpack %v, err: %v", tests.assetName, err)
+			continue
+		}
+		installed, err := ioutil.ReadFile(filepath.Join(folder, "tool"))
+		assert.NoError(t, err)
+		assert.Equal(t, tests.expectOut, string(installed), tests.assetName)
+	}
+
+	// maxFileSize is 1024 and a single compressed file don't have a size until it's unpacked
+	big := compress(t, formatZSTD, bytes.Repeat([]byte("a"), 2048))
+	assert.Error(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(big)), config.Bin{Cli: "tool"}, folder, "tool.zst", nil, false))
+	bigTar := compress(t, formatXZ, createTar(t, []archiveFile{{name: "tool", content: string(bytes.Repeat([]byte("a"), 2048))}}))
+	assert.Error(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(bigTar)), config.Bin{Cli: "tool"}, folder, "tool.tar.xz", nil, false))
+
+	// files needs a archive
+	assert.Error(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(compress(t, formatGZ, []byte("gz")))), config.Bin{Cli: "tool", Files: []config.File{{Path: "README.md"}}}, folder, "tool.gz", nil, false))
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// gpgSignatureSuffixes the default names of a gpg signature, example: terraform_1.0.0_SHA256SUMS.sig
+var gpgSignatureSuffixes = []string{".sig", ".asc", ".gpg"}
+
+// armoredSignature the start of a ascii armored signature, anything else is handled as a binary signature
+var armoredSignature = []byte("-----BEGIN PGP SIGNATURE-----")
+
+// gpgVerifier reads the public keys in key, both armored and binary keys is supported
+func gpgVerifier(gpg *config.GPG) (detachedVerifier, error) {
+	if gpg.Key == "" {
+		return nil, errors.New("gpg needs a key")
+	}
+	data, err := ioutil.ReadFile(expandHome(gpg.Key)) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("unable to read gpg key: %v", err)
+	}
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		if keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("unable to parse gpg key %v: %v", gpg.Key, err)
+		}
+	}
+
+	return func(signed blob, signature []byte) error {
+		rc, err := signed.open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		if bytes.HasPrefix(bytes.TrimSpace(signature), armoredSignature) {
+			_, err := openpgp.CheckArmoredDetachedSignature(keyring, rc, bytes.NewReader(signature), nil)
+			return err
+		}
+		_, err = openpgp.CheckDetachedSignature(keyring, rc, bytes.NewReader(signature), nil)
+		return err
+	}, nil
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestGPGKey writes the armored public key of a new entity to folder
+func newTestGPGKey(t *testing.T, folder, name string) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if err != nil {
+		t.Fatalf("Unable to create gpg entity %v", err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("Unable to armor gpg key %v", err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatalf("Unable to serialize gpg key %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unable to close armor %v", err)
+	}
+	location := filepath.Join(folder, name+".asc")
+	if err := ioutil.WriteFile(location, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Unable to write gpg key %v", err)
+	}
+	return entity, location
+}
+
+func TestVerifyGPG(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	viper.Set(config.DefaultHTTPtimeoutkey, 5)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024*1024)
+	folder := newTestSaveLocation(t, "testGPG")
+
+	signer, key := newTestGPGKey(t, folder, "hashicorp")
+	other, _ := newTestGPGKey(t, folder, "other")
+
+	data := []byte("the asset")
+	sum := sha256.Sum256(data)
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  tool_1.0.0_linux_amd64.zip\n")
+	detachSign := func(entity *openpgp.Entity, signed []byte, armored bool) string {
+		var buf bytes.Buffer
+		var err error
+		if armored {
+			err = openpgp.ArmoredDetachSign(&buf, entity, bytes.NewReader(signed), nil)
+		} else {
+			err = openpgp.DetachSign(&buf, entity, bytes.NewReader(signed), nil)
+		}
+		if err != nil {
+			t.Fatalf("Unable to sign %v", err)
+		}
+		return buf.String()
+	}
+
+	var files map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content)) // #nosec G104
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		checksumFile bool
+		files        map[string]string
+		data         []byte
+		expectErr    bool
+	}{
+		{name: "signed checksum file", checksumFile: true, files: map[string]string{"/SHA256SUMS": string(checksums), "/SHA256SUMS.sig": detachSign(signer, checksums, false)}},
+		{name: "armored signature", checksumFile: true, files: map[string]string{"/SHA256SUMS": string(checksums), "/SHA256SUMS.asc": detachSign(signer, checksums, true)}},
+		{name: "modified asset", checksumFile: true, files: map[string]string{"/SHA256SUMS": string(checksums), "/SHA256SUMS.sig": detachSign(signer, checksums, false)}, data: []byte("modified"), expectErr: true},
+		{name: "modified checksum file", checksumFile: true, files: map[string]string{"/SHA256SUMS": string(checksums) + "\n", "/SHA256SUMS.sig": detachSign(signer, checksums, false)}, expectErr: true},
+		{name: "unknown key", checksumFile: true, files: map[string]string{"/SHA256SUMS": string(checksums), "/SHA256SUMS.sig": detachSign(other, checksums, false)}, expectErr: true},
+		{name: "signed asset", files: map[string]string{"/tool_1.0.0_linux_amd64.zip.asc": detachSign(signer, data, true)}},
+		{name: "missing signature", checksumFile: true, files: map[string]string{"/SHA256SUMS": string(checksums)}, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		files = tests.files
+		binConfig := config.Bin{Cli: "tool", Verify: &config.Verify{GPG: &config.GPG{Key: key}}}
+		entry := lockEntry{AssetName: "tool_1.0.0_linux_amd64.zip", DownloadURL: server.URL + "/tool_1.0.0_linux_amd64.zip"}
+		if tests.checksumFile {
+			entry.checksumFile = &releaseFile{name: "SHA256SUMS", url: server.URL + "/SHA256SUMS"}
+		}
+		verifyData := data
+		if tests.data != nil {
+			verifyData = tests.data
+		}
+		err := verifyDownload(ctx, nil, server.Client(), binConfig, entry, bytesBlob(verifyData))
+		if tests.expectErr {
+			assert.Error(t, err, tests.name)
+			continue
+		}
+		assert.NoError(t, err, tests.name)
+	}
+
+	// a missing key file
+	binConfig := config.Bin{Cli: "tool", Verify: &config.Verify{GPG: &config.GPG{Key: filepath.Join(folder, "missing.asc")}}}
+	assert.Error(t, verifyDownload(ctx, nil, server.Client(), binConfig, lockEntry{AssetName: "tool.zip"}, bytesBlob(data)))
+}
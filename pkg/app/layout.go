@@ -0,0 +1,156 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/spf13/viper"
+)
+
+// folders inside the installLayout prefix
+const (
+	layoutBinDir      = "bin"
+	layoutManDir      = "share/man"
+	layoutBashDir     = "share/bash-completion/completions"
+	layoutZshDir      = "share/zsh/site-functions"
+	layoutFishDir     = "share/fish/vendor_completions.d"
+	layoutLicensesDir = "share/licenses"
+	layoutDocDir      = "share/doc"
+)
+
+// layoutFileMode is used for everything but binaries in a installLayout
+const layoutFileMode = os.FileMode(0644)
+
+var (
+	// manPage matches tool.1 and tool.conf.5.gz in a man folder, the group is the section
+	manPage = regexp.MustCompile(`^.+\.([1-9])(?:\.gz)?$`)
+	// plainManPage a man page outside of a man folder can't have any other dots or end with a digit, else tool-1.2.3 and python3.9 would be man pages
+	plainManPage = regexp.MustCompile(`^[^.]*[^.0-9]\.[1-9](?:\.gz)?$`)
+	// manDir matches man/ and man1/ to man9/ folders
+	manDir = regexp.MustCompile(`(^|/)man[1-9]?/`)
+	// completionDir matches folders like completions, autocomplete and contrib/completion
+	completionDir = regexp.MustCompile(`(?i)(^|/)[^/]*complet[^/]*/`)
+	licenseFile   = regexp.MustCompile(`(?i)^(license|licence|copying|notice|unlicense)([._-].*)?$`)
+	docFile       = regexp.MustCompile(`(?i)^(readme|changelog|changes|authors)([._-].*)?$`)
+)
+
+// layoutPrefix returns the installLayout prefix with ~ expanded, empty if no installLayout is used
+func layoutPrefix() string {
+	return expandHome(viper.GetString(config.DefaultInstallLayoutPrefixKey))
+}
+
+// expandHome replaces ~/ in the beginning of a path with the home folder
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if homedir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homedir, p[2:])
+		}
+	}
+	return p
+}
+
+// binLocation returns where the clis is installed, prefix/bin with a installLayout else saveLocation
+func binLocation() string {
+	if prefix := layoutPrefix(); prefix != "" {
+		return filepath.Join(prefix, layoutBinDir)
+	}
+	return viper.GetString(config.DefaultSaveLocationKey)
+}
+
+// layoutRules returns the rules of the bin followed by the default rules for man pages, completions, licenses and docs.
+// Nothing is required to be in the archive, if several members match a completion rule the last one wins.
+func layoutRules(binConfig config.Bin) ([]*extractRule, error) {
+	var rules []*extractRule
+	for _, layout := range binConfig.Layout {
+		if layout.Match == "" || layout.Dir == "" {
+			return nil, fmt.Errorf("%v: a layout rule needs match and dir", binConfig.Cli)
+		}
+		r, err := regexp.Compile(layout.Match)
+		if err != nil {
+			return nil, fmt.Errorf("%v: invalid layout match %q: %v", binConfig.Cli, layout.Match, err)
+		}
+		mode, err := parseMode(binConfig.Cli, layout.Mode, layoutFileMode)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, &extractRule{description: layout.Match, match: r.MatchString, dir: filepath.FromSlash(layout.Dir), name: layout.Name, mode: mode, optional: true})
+	}
+
+	cliName := binConfig.InstallName()
+	isCompletion := func(memberPath, shell string) bool {
+		if !completionDir.MatchString(memberPath) {
+			return false
+		}
+		base := strings.ToLower(path.Base(memberPath))
+		return strings.Contains(base, shell) || path.Base(path.Dir(memberPath)) == shell
+	}
+
+	return append(rules,
+		&extractRule{
+			description: "man pages",
+			match:       isManPage,
+			dirFunc: func(memberPath string) string {
+				return filepath.Join(filepath.FromSlash(layoutManDir), "man"+manPage.FindStringSubmatch(path.Base(memberPath))[1])
+			},
+			mode:     layoutFileMode,
+			optional: true,
+		},
+		&extractRule{
+			description: "bash completion",
+			match:       func(memberPath string) bool { return isCompletion(memberPath, "bash") },
+			dir:         filepath.FromSlash(layoutBashDir),
+			name:        cliName,
+			mode:        layoutFileMode,
+			optional:    true,
+		},
+		&extractRule{
+			description: "zsh completion",
+			match: func(memberPath string) bool {
+				return isCompletion(memberPath, "zsh") || (completionDir.MatchString(memberPath) && strings.HasPrefix(path.Base(memberPath), "_"))
+			},
+			dir:      filepath.FromSlash(layoutZshDir),
+			name:     "_" + cliName,
+			mode:     layoutFileMode,
+			optional: true,
+		},
+		&extractRule{
+			description: "fish completion",
+			match:       func(memberPath string) bool { return strings.HasSuffix(memberPath, ".fish") },
+			dir:         filepath.FromSlash(layoutFishDir),
+			name:        cliName + ".fish",
+			mode:        layoutFileMode,
+			optional:    true,
+		},
+		&extractRule{
+			description: "licenses",
+			match:       func(memberPath string) bool { return licenseFile.MatchString(path.Base(memberPath)) },
+			dir:         filepath.Join(filepath.FromSlash(layoutLicensesDir), cliName),
+			mode:        layoutFileMode,
+			optional:    true,
+		},
+		&extractRule{
+			description: "docs",
+			match:       func(memberPath string) bool { return docFile.MatchString(path.Base(memberPath)) },
+			dir:         filepath.Join(filepath.FromSlash(layoutDocDir), cliName),
+			mode:        layoutFileMode,
+			optional:    true,
+		},
+	), nil
+}
+
+// isManPage returns true for tool.1 and for anything ending with a section in a man folder, libraries like libtool.so.1 is never man pages
+func isManPage(memberPath string) bool {
+	if strings.Contains(memberPath, ".so.") {
+		return false
+	}
+	base := path.Base(memberPath)
+	if manDir.MatchString(memberPath) {
+		return manPage.MatchString(base)
+	}
+	return plainManPage.MatchString(base)
+}
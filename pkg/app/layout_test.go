@@ -0,0 +1,121 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallLayout(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	prefix := newTestSaveLocation(t, "testLayout")
+
+	archive := createTarGZ(t, []archiveFile{
+		{name: "tool-1.0.0/tool", content: "tool"},
+		{name: "tool-1.0.0/toold", content: "daemon"},
+		{name: "tool-1.0.0/man/tool.1", content: "man1"},
+		{name: "tool-1.0.0/man/tool.conf.5.gz", content: "man5"},
+		{name: "tool-1.0.0/completions/tool.bash", content: "bash"},
+		{name: "tool-1.0.0/completions/_tool", content: "zsh"},
+		{name: "tool-1.0.0/completions/tool.fish", content: "fish"},
+		{name: "tool-1.0.0/LICENSE", content: "license"},
+		{name: "tool-1.0.0/README.md", content: "readme"},
+		{name: "tool-1.0.0/examples/config.yaml", content: "example"},
+		{name: "tool-1.0.0/lib/libtool.so.1", content: "lib"},
+	})
+	bin := config.Bin{
+		Cli:       "tool",
+		InstallAs: "tool2",
+		Files:     []config.File{{Path: "*/toold"}},
+		Layout:    []config.LayoutRule{{Match: "^[^/]+/examples/", Dir: "share/tool/examples"}},
+	}
+	if err := pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(archive)), bin, prefix, "tool.tar.gz", nil, true); err != nil {
+		t.Fatalf("Unable to install with layout %v", err)
+	}
+
+	expected := map[string]string{
+		"bin/tool2":                                  "tool",
+		"bin/toold":                                  "daemon",
+		"share/man/man1/tool.1":                      "man1",
+		"share/man/man5/tool.conf.5.gz":              "man5",
+		"share/bash-completion/completions/tool2":    "bash",
+		"share/zsh/site-functions/_tool2":            "zsh",
+		"share/fish/vendor_completions.d/tool2.fish": "fish",
+		"share/licenses/tool2/LICENSE":               "license",
+		"share/doc/tool2/README.md":                  "readme",
+		"share/tool/examples/config.yaml":            "example",
+	}
+	for location, content := range expected {
+		installed, err := ioutil.ReadFile(filepath.Join(prefix, filepath.FromSlash(location)))
+		if err != nil {
+			t.Errorf("Missing %v, err: %v", location, err)
+			continue
+		}
+		assert.Equal(t, content, string(installed), location)
+	}
+	stat, err := os.Stat(filepath.Join(prefix, "share", "man", "man1", "tool.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, layoutFileMode, stat.Mode().Perm())
+	_, err = os.Stat(filepath.Join(prefix, "share", "man", "man1", "libtool.so.1"))
+	assert.True(t, os.IsNotExist(err), "libraries is not man pages")
+
+	// a single file only have the cli
+	elf := append([]byte{0x7f, 'E', 'L', 'F'}, []byte("binary")...)
+	assert.NoError(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(elf)), config.Bin{Cli: "single"}, prefix, "single", nil, true))
+	_, err = os.Stat(filepath.Join(prefix, "bin", "single"))
+	assert.NoError(t, err)
+
+	// layout rules needs match and dir
+	_, err = extractRules(config.Bin{Cli: "tool", Layout: []config.LayoutRule{{Match: "^doc/"}}}, true)
+	assert.Error(t, err)
+	_, err = extractRules(config.Bin{Cli: "tool", Layout: []config.LayoutRule{{Match: "(", Dir: "share"}}}, true)
+	assert.Error(t, err)
+}
+
+func TestIsManPage(t *testing.T) {
+	tests := []struct {
+		memberPath string
+		expectOut  bool
+	}{
+		{memberPath: "tool.1", expectOut: true},
+		{memberPath: "tool-1.0.0/git-lfs.1.gz", expectOut: true},
+		{memberPath: "tool-1.0.0/man/tool.conf.5.gz", expectOut: true},
+		{memberPath: "share/man/man8/tool-1.2.8", expectOut: true},
+		{memberPath: "tool-v1.2.3", expectOut: false},
+		{memberPath: "kubectl-1.29.1", expectOut: false},
+		{memberPath: "bin/python3.9", expectOut: false},
+		{memberPath: "tool.conf.5", expectOut: false},
+		{memberPath: "man/libtool.so.1", expectOut: false},
+		{memberPath: "manual/tool-1.2.3", expectOut: false},
+	}
+
+	for _, tests := range tests {
+		assert.Equal(t, tests.expectOut, isManPage(tests.memberPath), tests.memberPath)
+	}
+}
+
+func TestCreateJobsLayout(t *testing.T) {
+	defer viper.Reset()
+	viper.Set(config.DefaultSaveLocationKey, "/save")
+	viper.Set(config.DefaultInstallLayoutPrefixKey, "/prefix")
+
+	jobs, err := createJobs(&config.Items{Bins: []config.Bin{{Cli: "tkn"}, {Cli: "helm", Platforms: []string{"darwin/arm64"}}}}, hostPlatform())
+	if err != nil {
+		t.Fatalf("Unable to create jobs %v", err)
+	}
+	assert.Equal(t, "/prefix", jobs[0].layoutPrefix)
+	assert.Equal(t, filepath.Join("/prefix", "bin"), jobs[0].saveLocation)
+	assert.Equal(t, filepath.Join("/prefix", "bin"), jobs[0].backupLocation)
+	assert.Equal(t, filepath.Join("/prefix", "darwin_arm64"), jobs[1].layoutPrefix)
+	assert.Equal(t, filepath.Join("/prefix", "darwin_arm64", "bin"), jobs[1].saveLocation)
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/google/go-github/v33/github"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// lockFileName is saved next to the config file
+const lockFileName = "githubbindl.lock"
+
+// lockEntry what got resolved and downloaded for a bin
+type lockEntry struct {
+	Cli string `yaml:"cli"`
+	// Platform is only set when the bin is downloaded for several platforms
+	Platform    string `yaml:"platform,omitempty"`
+	Tag         string `yaml:"tag,omitempty"`
+	AssetName   string `yaml:"assetName"`
+	AssetID     int64  `yaml:"assetID,omitempty"`
+	DownloadURL string `yaml:"downloadURL"`
+	Size        int64  `yaml:"size"`
+	SHA256      string `yaml:"sha256"`
+
+	// checksumFile and release is only set when the entry is resolved from a release, the lock file sha256 is enough with frozen
+	checksumFile *releaseFile
+	release      *github.RepositoryRelease
+}
+
+// lockFile the content of githubbindl.lock, the mutex is needed since all bins are downloaded at the same time
+type lockFile struct {
+	Bins []lockEntry `yaml:"bins"`
+
+	mu      sync.Mutex
+	entries map[string]lockEntry
+}
+
+// lockFileLocation returns the path to the lock file in the same folder as the config file
+func lockFileLocation() string {
+	return filepath.Join(filepath.Dir(viper.ConfigFileUsed()), lockFileName)
+}
+
+// readLockFile reads the lock file, if it don't exist a empty lock is returned unless frozen is used
+func readLockFile(location string) (*lockFile, error) {
+	lock := &lockFile{entries: make(map[string]lockEntry)}
+
+	source, err := ioutil.ReadFile(location) // #nosec G304
+	if err != nil {
+		if os.IsNotExist(err) && !viper.GetBool(config.DefaultFrozenKey) {
+			return lock, nil
+		}
+		return nil, fmt.Errorf("unable to read lock file: %v", err)
+	}
+
+	if err := yaml.Unmarshal(source, lock); err != nil {
+		return nil, fmt.Errorf("unable to parse lock file %v: %v", location, err)
+	}
+	for _, entry := range lock.Bins {
+		lock.entries[entry.key()] = entry
+	}
+	return lock, nil
+}
+
+// key is the cli and the platform if set, example: linux/amd64/tkn
+func (l lockEntry) key() string {
+	if l.Platform == "" {
+		return l.Cli
+	}
+	return l.Platform + "/" + l.Cli
+}
+
+// set adds or replaces the entry for a cli
+func (l *lockFile) set(entry lockEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[entry.key()] = entry
+}
+
+// frozenEntry returns the locked entry for the job and verifies that it still matches the config
+func (l *lockFile) frozenEntry(j job) (lockEntry, error) {
+	binConfig := j.bin
+	l.mu.Lock()
+	entry, ok := l.entries[j.lockKey()]
+	l.mu.Unlock()
+
+	if !ok {
+		return lockEntry{}, fmt.Errorf("%v: missing in the lock file, run update to add it", j.lockKey())
+	}
+	if binConfig.Tag != "" && binConfig.Tag != entry.Tag {
+		return lockEntry{}, fmt.Errorf("%v: tag %v differs from the lock file tag %v", binConfig.Cli, binConfig.Tag, entry.Tag)
+	}
+	// with versionFrom the url depends on the version, the lock file decides which version to use
+	if binConfig.NonGithubURL != "" && binConfig.VersionFrom == nil && binConfig.NonGithubURL != entry.DownloadURL {
+		return lockEntry{}, fmt.Errorf("%v: nonGithubURL %v differs from the lock file url %v", binConfig.Cli, binConfig.NonGithubURL, entry.DownloadURL)
+	}
+	if binConfig.NonGithubURL == "" && entry.AssetID == 0 {
+		return lockEntry{}, fmt.Errorf("%v: the lock file don't contain a github asset, run update to refresh it", binConfig.Cli)
+	}
+	if binConfig.NonGithubURL == "" && binConfig.Tag == "" {
+		if err := checkLockedRelease(binConfig, entry); err != nil {
+			return lockEntry{}, err
+		}
+	}
+	return entry, nil
+}
+
+// checkLockedRelease returns a error if the locked tag or asset isn't one that the config could resolve to,
+// like when version is changed but the lock file isn't updated
+func checkLockedRelease(binConfig config.Bin, entry lockEntry) error {
+	filter, err := newTagFilter(binConfig)
+	if err != nil {
+		return err
+	}
+	v, matched, parsed := filter.version(entry.Tag)
+	switch {
+	case !matched:
+		return fmt.Errorf("%v: the lock file tag %v is excluded by excludeTags or don't match tagPattern, run update to refresh it", binConfig.Cli, entry.Tag)
+	case !parsed && (binConfig.Version != "" || binConfig.TagPrefix != ""):
+		return fmt.Errorf("%v: the lock file tag %v isn't a version, run update to refresh it", binConfig.Cli, entry.Tag)
+	case parsed && !filter.constraint.check(v):
+		return fmt.Errorf("%v: the lock file tag %v don't satisfy version %q, run update to refresh it", binConfig.Cli, entry.Tag, binConfig.Version)
+	}
+
+	if binConfig.Match != "" {
+		match, err := regexp.Compile(strings.ToLower(binConfig.Match))
+		if err != nil {
+			return fmt.Errorf("%v: invalid match pattern %q: %v", binConfig.Cli, binConfig.Match, err)
+		}
+		if !match.MatchString(strings.ToLower(entry.AssetName)) {
+			return fmt.Errorf("%v: the lock file asset %v don't match %q, run update to refresh it", binConfig.Cli, entry.AssetName, binConfig.Match)
+		}
+	}
+	return nil
+}
+
+// write saves the lock file, entries for bins that no longer is in the config is removed
+func (l *lockFile) write(location string, jobs []job) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.Bins = nil
+	for _, j := range jobs {
+		if entry, ok := l.entries[j.lockKey()]; ok {
+			l.Bins = append(l.Bins, entry)
+		}
+	}
+	sort.Slice(l.Bins, func(i, j int) bool {
+		return strings.Compare(l.Bins[i].key(), l.Bins[j].key()) < 0
+	})
+
+	out, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(location, out, 0644) // #nosec G306
+}
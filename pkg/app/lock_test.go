@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// the lock file is written after a normal install and frozen fails as soon as the downloaded file changes
+func TestLockFile(t *testing.T) {
+	content := "#!/bin/sh\necho v1\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+
+	workspace := getEnv("TEMP_DIR", "/tmp")
+	folder, err := ioutil.TempDir(workspace, "testLock")
+	if err != nil {
+		t.Fatalf("Unable to create a tmp dir %v", err)
+	}
+	defer viper.Reset()
+	viper.Set(config.DefaultSaveLocationKey, folder)
+	viper.Set(config.DefaultHTTPtimeoutkey, 5)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024*1024)
+	lockLocation := filepath.Join(folder, lockFileName)
+
+	jobs := []job{{bin: config.Bin{Cli: "mycli", NonGithubURL: server.URL + "/mycli"}, platform: hostPlatform(), saveLocation: folder, backupLocation: folder}}
+
+	lock, err := readLockFile(lockLocation)
+	if err != nil {
+		t.Fatalf("A missing lock file should give a empty lock, err: %v", err)
+	}
+	state, err := readStateFile(folder)
+	if err != nil {
+		t.Fatalf("A missing state file should give a empty state, err: %v", err)
+	}
+	jobs[0].state = state
+	err = installBin(ctx, nil, server.Client(), jobs[0], lock)
+	if err != nil {
+		t.Fatalf("Unable to install bin %v", err)
+	}
+	if err := lock.write(lockLocation, jobs); err != nil {
+		t.Fatalf("Unable to write lock file %v", err)
+	}
+
+	installed, err := ioutil.ReadFile(filepath.Join(folder, "mycli"))
+	if err != nil {
+		t.Fatalf("Unable to read installed file %v", err)
+	}
+	assert.Equal(t, content, string(installed))
+
+	// frozen installs what is in the lock file
+	viper.Set(config.DefaultFrozenKey, true)
+	lock, err = readLockFile(lockLocation)
+	if err != nil {
+		t.Fatalf("Unable to read lock file %v", err)
+	}
+	assert.Len(t, lock.Bins, 1)
+	assert.Equal(t, int64(len(content)), lock.Bins[0].Size)
+	assert.Equal(t, "mycli", lock.Bins[0].AssetName)
+	assert.NoError(t, installBin(ctx, nil, server.Client(), jobs[0], lock))
+
+	// a new file on the server is not what the lock file says, use a empty state to force a new download
+	content = "#!/bin/sh\necho v2\n"
+	jobs[0].state = &stateFile{entries: make(map[string]stateEntry)}
+	assert.Error(t, installBin(ctx, nil, server.Client(), jobs[0], lock))
+
+	// a bin that is not in the lock file
+	other := jobs[0]
+	other.bin = config.Bin{Cli: "other", NonGithubURL: server.URL + "/other"}
+	assert.Error(t, installBin(ctx, nil, server.Client(), other, lock))
+
+	// frozen requires a lock file
+	_, err = readLockFile(filepath.Join(folder, "missing.lock"))
+	assert.Error(t, err)
+}
+
+// frozen fails when the locked release is no longer one that the config could resolve to
+func TestFrozenEntry(t *testing.T) {
+	entry := lockEntry{Cli: "tkn", Tag: "v0.15.0", AssetName: "tkn_0.15.0_Linux_x86_64.tar.gz", AssetID: 1}
+	lock := &lockFile{entries: map[string]lockEntry{"tkn": entry}}
+
+	tests := []struct {
+		name      string
+		bin       config.Bin
+		expectErr bool
+	}{
+		{name: "latest", bin: config.Bin{}},
+		{name: "version", bin: config.Bin{Version: "^0.15.0"}},
+		{name: "version changed", bin: config.Bin{Version: ">=0.16.0"}, expectErr: true},
+		{name: "excluded tag", bin: config.Bin{ExcludeTags: []string{`^v0\.15\.`}}, expectErr: true},
+		{name: "tagPattern", bin: config.Bin{TagPattern: `^release-(.*)$`}, expectErr: true},
+		{name: "match", bin: config.Bin{Match: `linux_x86_64\.tar\.gz$`}},
+		{name: "match changed", bin: config.Bin{Match: `darwin`}, expectErr: true},
+		{name: "tag", bin: config.Bin{Tag: "v0.15.0", Version: ">=0.16.0"}},
+		{name: "tag changed", bin: config.Bin{Tag: "v0.16.0"}, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		bin := tests.bin
+		bin.Cli = "tkn"
+		_, err := lock.frozenEntry(job{bin: bin, platform: hostPlatform()})
+		if tests.expectErr {
+			assert.Error(t, err, tests.name)
+			continue
+		}
+		assert.NoError(t, err, tests.name)
+	}
+}
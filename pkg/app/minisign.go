@@ -0,0 +1,125 @@
+package app
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignSignatureSuffixes the default name of a minisign signature, example: tool.tar.gz.minisig
+var minisignSignatureSuffixes = []string{".minisig"}
+
+// minisign algorithms, Ed signs the file and ED signs the blake2b-512 of the file
+const (
+	minisignLegacy    = "Ed"
+	minisignPrehashed = "ED"
+)
+
+const (
+	minisignKeyIDSize     = 8
+	minisignTrustedPrefix = "trusted comment: "
+)
+
+// minisignPublicKey a public key, base64 of the algorithm, the key ID and the ed25519 key
+type minisignPublicKey struct {
+	keyID [minisignKeyIDSize]byte
+	key   ed25519.PublicKey
+}
+
+// parseMinisignPublicKey parses the key like minisign -G prints it, the content of a minisign.pub file with the untrusted comment also works
+func parseMinisignPublicKey(s string) (minisignPublicKey, error) {
+	var publicKey minisignPublicKey
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil {
+		return publicKey, fmt.Errorf("invalid minisign public key: %v", err)
+	}
+	if len(decoded) != 2+minisignKeyIDSize+ed25519.PublicKeySize || string(decoded[:2]) != minisignLegacy {
+		return publicKey, errors.New("invalid minisign public key, expected a ed25519 key")
+	}
+	copy(publicKey.keyID[:], decoded[2:2+minisignKeyIDSize])
+	publicKey.key = ed25519.PublicKey(decoded[2+minisignKeyIDSize:])
+	return publicKey, nil
+}
+
+// minisignVerifier verifies minisign signatures with the configured public key
+func minisignVerifier(minisign *config.Minisign) (detachedVerifier, error) {
+	if minisign.PublicKey == "" {
+		return nil, errors.New("minisign needs a publicKey")
+	}
+	publicKey, err := parseMinisignPublicKey(minisign.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return func(signed blob, signature []byte) error {
+		return verifyMinisign(publicKey, signed, signature)
+	}, nil
+}
+
+// verifyMinisign verifies a .minisig file, it's four lines: a untrusted comment, the signature, a trusted comment
+// and a global signature of the signature and the trusted comment.
+func verifyMinisign(publicKey minisignPublicKey, signed blob, signature []byte) error {
+	lines := strings.Split(strings.TrimSpace(strings.Replace(string(signature), "\r\n", "\n", -1)), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], minisignTrustedPrefix) {
+		return errors.New("invalid minisign signature, expected a untrusted comment, a signature, a trusted comment and a global signature")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil {
+		return fmt.Errorf("invalid minisign signature: %v", err)
+	}
+	if len(decoded) != 2+minisignKeyIDSize+ed25519.SignatureSize {
+		return errors.New("invalid minisign signature length")
+	}
+	algorithm, keyID, sig := string(decoded[:2]), decoded[2:2+minisignKeyIDSize], decoded[2+minisignKeyIDSize:]
+	if !bytes.Equal(keyID, publicKey.keyID[:]) {
+		return fmt.Errorf("signed with key %X, expected key %X", reverse(keyID), reverse(publicKey.keyID[:]))
+	}
+
+	var message []byte
+	switch algorithm {
+	case minisignLegacy:
+		// the legacy algorithm signs the content itself, it can't be streamed
+		message, err = readBlob(signed)
+	case minisignPrehashed:
+		var h hash.Hash
+		if h, err = blake2b.New512(nil); err == nil {
+			message, err = blobDigest(signed, h)
+		}
+	default:
+		return fmt.Errorf("unsupported minisign algorithm %q", algorithm)
+	}
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey.key, message, sig) {
+		return errors.New("minisign verification failed")
+	}
+
+	// the trusted comment is signed together with the signature
+	globalSignature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return fmt.Errorf("invalid minisign global signature: %v", err)
+	}
+	trustedComment := strings.TrimPrefix(lines[2], minisignTrustedPrefix)
+	if !ed25519.Verify(publicKey.key, append(sig, []byte(trustedComment)...), globalSignature) {
+		return errors.New("minisign verification of the trusted comment failed")
+	}
+	return nil
+}
+
+// reverse returns the bytes in reverse order, minisign prints the key ID as a little endian number
+func reverse(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	return reversed
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignSign creates a .minisig like minisign -S, prehashed is the default since minisign 0.10
+func minisignSign(privateKey ed25519.PrivateKey, keyID []byte, data []byte, prehashed bool, trustedComment string) string {
+	algorithm, message := minisignLegacy, data
+	if prehashed {
+		sum := blake2b.Sum512(data)
+		algorithm, message = minisignPrehashed, sum[:]
+	}
+	sig := ed25519.Sign(privateKey, message)
+	globalSignature := ed25519.Sign(privateKey, append(append([]byte{}, sig...), []byte(trustedComment)...))
+
+	encoded := append(append([]byte(algorithm), keyID...), sig...)
+	return strings.Join([]string{
+		"untrusted comment: signature from minisign secret key",
+		base64.StdEncoding.EncodeToString(encoded),
+		minisignTrustedPrefix + trustedComment,
+		base64.StdEncoding.EncodeToString(globalSignature),
+	}, "\n") + "\n"
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate key %v", err)
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	encodedKey := base64.StdEncoding.EncodeToString(append(append([]byte(minisignLegacy), keyID...), publicKey...))
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate key %v", err)
+	}
+
+	data := []byte("SHA256SUMS content")
+	comment := "timestamp:1700000000\tfile:SHA256SUMS"
+	tampered := strings.Replace(minisignSign(privateKey, keyID, data, true, comment), "timestamp:1700000000", "timestamp:1800000000", 1)
+
+	tests := []struct {
+		name      string
+		publicKey string
+		signature string
+		data      []byte
+		expectErr bool
+	}{
+		{name: "prehashed", publicKey: encodedKey, signature: minisignSign(privateKey, keyID, data, true, comment)},
+		{name: "legacy", publicKey: encodedKey, signature: minisignSign(privateKey, keyID, data, false, comment)},
+		{name: "pub file", publicKey: "untrusted comment: minisign public key 0807060504030201\n" + encodedKey + "\n", signature: minisignSign(privateKey, keyID, data, true, comment)},
+		{name: "modified file", publicKey: encodedKey, signature: minisignSign(privateKey, keyID, data, true, comment), data: []byte("modified"), expectErr: true},
+		{name: "other key", publicKey: encodedKey, signature: minisignSign(otherKey, keyID, data, true, comment), expectErr: true},
+		{name: "other key ID", publicKey: encodedKey, signature: minisignSign(privateKey, []byte{8, 7, 6, 5, 4, 3, 2, 1}, data, true, comment), expectErr: true},
+		{name: "modified trusted comment", publicKey: encodedKey, signature: tampered, expectErr: true},
+		{name: "broken signature", publicKey: encodedKey, signature: "untrusted comment: nothing\n", expectErr: true},
+		{name: "invalid key", publicKey: "RWQ", signature: minisignSign(privateKey, keyID, data, true, comment), expectErr: true},
+	}
+
+	for _, tests := range tests {
+		verify, err := minisignVerifier(&config.Minisign{PublicKey: tests.publicKey})
+		if err == nil {
+			signed := data
+			if tests.data != nil {
+				signed = tests.data
+			}
+			err = verify(bytesBlob(signed), []byte(tests.signature))
+		}
+		if tests.expectErr {
+			assert.Error(t, err, tests.name)
+			continue
+		}
+		assert.NoError(t, err, tests.name)
+	}
+}
@@ -0,0 +1,207 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	"github.com/spf13/viper"
+)
+
+// package formats, the files are extracted without dpkg or rpm
+const (
+	formatDeb = "deb"
+	formatRPM = "rpm"
+)
+
+var (
+	arMagic        = []byte("!<arch>\n")
+	rpmLeadMagic   = []byte{0xed, 0xab, 0xee, 0xdb}
+	rpmHeaderMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+)
+
+const (
+	// arHeaderSize name, mtime, uid, gid, mode, size and a end marker
+	arHeaderSize = 60
+	// rpmLeadSize the old lead before the signature header
+	rpmLeadSize = 96
+	// cpioHeaderSize the newc header, magic and 13 hex fields of 8 characters
+	cpioHeaderSize = 110
+	cpioTrailer    = "TRAILER!!!"
+	// cpioTypeMask and cpioTypeReg is the file type part of the mode
+	cpioTypeMask = 0170000
+	cpioTypeReg  = 0100000
+)
+
+// undeb finds data.tar.* in the ar container of a .deb and unpacks it
+func undeb(ctx context.Context, dst string, rules []*extractRule, r io.Reader) error {
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, arMagic) {
+		return errors.New("not a deb, the ar header is missing")
+	}
+
+	header := make([]byte, arHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return errors.New("data.tar is missing in the deb")
+			}
+			return err
+		}
+		name := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid ar member size of %v: %v", name, err)
+		}
+
+		if strings.HasPrefix(name, "data.tar") {
+			member := io.LimitReader(r, size)
+			if name == "data.tar" {
+				return untar(ctx, dst, rules, member)
+			}
+			compression, ok := compressions[strings.TrimPrefix(name, "data.")]
+			if !ok {
+				return fmt.Errorf("unsupported deb payload %v", name)
+			}
+			dr, err := decompress(compression, member)
+			if err != nil {
+				return err
+			}
+			defer dr.Close()
+			return untar(ctx, dst, rules, dr)
+		}
+
+		// members are padded to a even size
+		if _, err := io.CopyN(ioutil.Discard, r, size+size%2); err != nil {
+			return err
+		}
+	}
+}
+
+// unrpm skips the lead, signature and header of a .rpm and unpacks the cpio payload
+func unrpm(ctx context.Context, dst string, rules []*extractRule, r io.Reader) error {
+	lead := make([]byte, rpmLeadSize)
+	if _, err := io.ReadFull(r, lead); err != nil || !bytes.HasPrefix(lead, rpmLeadMagic) {
+		return errors.New("not a rpm, the lead is missing")
+	}
+	// the signature header is padded to 8 bytes, the main header is not
+	if err := skipRPMHeader(r, true); err != nil {
+		return err
+	}
+	if err := skipRPMHeader(r, false); err != nil {
+		return err
+	}
+
+	payload := bufio.NewReaderSize(r, sniffLength)
+	head, _ := payload.Peek(sniffLength)
+	if bytes.HasPrefix(head, []byte("0707")) {
+		return uncpio(ctx, dst, rules, payload)
+	}
+	format, err := detectFormat("", head, nil, "")
+	if err != nil {
+		return errors.New("unsupported rpm payload compression")
+	}
+	compression, ok := compressions[format]
+	if !ok {
+		return fmt.Errorf("unsupported rpm payload %v", format)
+	}
+	dr, err := decompress(compression, payload)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+	return uncpio(ctx, dst, rules, dr)
+}
+
+// skipRPMHeader reads past a rpm header structure, 16 bytes intro, 16 bytes per index entry and the data store
+func skipRPMHeader(r io.Reader, pad bool) error {
+	intro := make([]byte, 16)
+	if _, err := io.ReadFull(r, intro); err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(intro, rpmHeaderMagic) {
+		return errors.New("invalid rpm header")
+	}
+	entries := int64(binary.BigEndian.Uint32(intro[8:12]))
+	dataSize := int64(binary.BigEndian.Uint32(intro[12:16]))
+	size := entries*16 + dataSize
+	if pad {
+		size += (8 - (16+size)%8) % 8
+	}
+	_, err := io.CopyN(ioutil.Discard, r, size)
+	return err
+}
+
+// uncpio unpacks a cpio archive in the newc format and put the files matching the rules in any folder you want
+func uncpio(ctx context.Context, dst string, rules []*extractRule, r io.Reader) error {
+	log := logr.FromContext(ctx)
+	header := make([]byte, cpioHeaderSize)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return fmt.Errorf("invalid cpio payload: %v", err)
+		}
+		magic := string(header[0:6])
+		if magic != "070701" && magic != "070702" {
+			return fmt.Errorf("unsupported cpio format %q", magic)
+		}
+		field := func(i int) (int64, error) {
+			return strconv.ParseInt(string(header[6+i*8:14+i*8]), 16, 64)
+		}
+		mode, err := field(1)
+		if err != nil {
+			return err
+		}
+		fileSize, err := field(6)
+		if err != nil {
+			return err
+		}
+		nameSize, err := field(11)
+		if err != nil {
+			return err
+		}
+
+		// the name ends with a NUL and the header and name is padded to 4 bytes
+		name := make([]byte, nameSize+(4-(cpioHeaderSize+nameSize)%4)%4)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return err
+		}
+		memberPath := cleanMemberPath(strings.TrimRight(string(name), "\x00"))
+		if memberPath == cpioTrailer {
+			return checkRules(rules)
+		}
+
+		data := io.LimitReader(r, fileSize)
+		if rule := matchRule(rules, memberPath); rule != nil && mode&cpioTypeMask == cpioTypeReg {
+			rule.found++
+
+			maxFileSize := viper.GetInt64(config.DefaultMaxFileSizeKey)
+			// Fix G110 max size of a unpacked file
+			if fileSize > maxFileSize {
+				return fmt.Errorf("%v: is %v which is bigger than allowed maxFileSize %v byte", memberPath, fileSize, maxFileSize)
+			}
+			log.Info(memberPath)
+
+			if err := writeFile(dst, rule.target(dst, memberPath), rule.mode, data); err != nil {
+				return err
+			}
+		}
+
+		// skip what is left of the file and the padding to 4 bytes
+		if _, err := io.CopyN(ioutil.Discard, data, fileSize); err != nil && err != io.EOF {
+			return err
+		}
+		if _, err := io.CopyN(ioutil.Discard, r, (4-fileSize%4)%4); err != nil {
+			return err
+		}
+	}
+}
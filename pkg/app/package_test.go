@@ -0,0 +1,118 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// createDeb returns a ar container with the members in order, a real deb have debian-binary, control.tar.* and data.tar.*
+func createDeb(members []archiveFile) []byte {
+	var buf bytes.Buffer
+	buf.Write(arMagic)
+	for _, member := range members {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", member.name+"/", 0, 0, 0, "100644", len(member.content))
+		buf.WriteString(member.content)
+		if len(member.content)%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// createCpio returns a cpio archive in the newc format
+func createCpio(files []archiveFile) []byte {
+	var buf bytes.Buffer
+	pad := func(n int) {
+		buf.Write(make([]byte, (4-n%4)%4))
+	}
+	write := func(name, content string, mode int) {
+		fmt.Fprintf(&buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X", 0, mode, 0, 0, 1, 0, len(content), 0, 0, 0, 0, len(name)+1, 0)
+		buf.WriteString(name + "\x00")
+		pad(cpioHeaderSize + len(name) + 1)
+		buf.WriteString(content)
+		pad(len(content))
+	}
+	write("./usr", "", 040755)
+	for _, file := range files {
+		write(file.name, file.content, 0100755)
+	}
+	write(cpioTrailer, "", 0)
+	return buf.Bytes()
+}
+
+// createRPM returns a rpm with a lead, a signature and main header with one entry each and the payload
+func createRPM(payload []byte) []byte {
+	var buf bytes.Buffer
+	lead := make([]byte, rpmLeadSize)
+	copy(lead, rpmLeadMagic)
+	buf.Write(lead)
+
+	header := func(dataSize int, pad bool) {
+		buf.Write(rpmHeaderMagic)
+		buf.Write(make([]byte, 4))
+		_ = binary.Write(&buf, binary.BigEndian, uint32(1))
+		_ = binary.Write(&buf, binary.BigEndian, uint32(dataSize))
+		buf.Write(make([]byte, 16+dataSize))
+		if pad {
+			buf.Write(make([]byte, (8-(32+dataSize)%8)%8))
+		}
+	}
+	header(5, true)
+	header(7, false)
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func TestUnpackPackages(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	folder := newTestSaveLocation(t, "testPackages")
+
+	dataTar := createTar(t, []archiveFile{{name: "./usr/bin/tool", content: "from deb"}, {name: "./usr/share/doc/tool/copyright", content: "license"}})
+	controlTar := compress(t, formatGZ, createTar(t, []archiveFile{{name: "./control", content: "Package: tool"}}))
+	cpio := createCpio([]archiveFile{{name: "./usr/bin/tool", content: "from rpm"}, {name: "./usr/share/man/man1/tool.1", content: "man"}})
+
+	tests := []struct {
+		assetName string
+		data      []byte
+		expectOut string
+	}{
+		{assetName: "tool_1.0.0_amd64.deb", data: createDeb([]archiveFile{{name: "debian-binary", content: "2.0\n"}, {name: "control.tar.gz", content: string(controlTar)}, {name: "data.tar.xz", content: string(compress(t, formatXZ, dataTar))}}), expectOut: "from deb"},
+		{assetName: "tool_1.0.0_amd64.deb", data: createDeb([]archiveFile{{name: "debian-binary", content: "2.0\n"}, {name: "control.tar.gz", content: string(controlTar)}, {name: "data.tar.zst", content: string(compress(t, formatZSTD, dataTar))}}), expectOut: "from deb"},
+		{assetName: "download", data: createDeb([]archiveFile{{name: "debian-binary", content: "2.0\n"}, {name: "data.tar", content: string(dataTar)}}), expectOut: "from deb"},
+		{assetName: "tool-1.0.0-1.x86_64.rpm", data: createRPM(compress(t, formatGZ, cpio)), expectOut: "from rpm"},
+		{assetName: "tool-1.0.0-1.x86_64.rpm", data: createRPM(compress(t, formatXZ, cpio)), expectOut: "from rpm"},
+		{assetName: "download", data: createRPM(cpio), expectOut: "from rpm"},
+	}
+
+	for _, tests := range tests {
+		bin := config.Bin{Cli: "tool", Files: []config.File{{Match: "^usr/share/"}}}
+		err := pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(tests.data)), bin, folder, tests.assetName, nil, false)
+		if err != nil {
+			t.Errorf("Unable to unpack %v, err: %v", tests.assetName, err)
+			continue
+		}
+		installed, err := ioutil.ReadFile(filepath.Join(folder, "tool"))
+		assert.NoError(t, err)
+		assert.Equal(t, tests.expectOut, string(installed), tests.assetName)
+	}
+	_, err := ioutil.ReadFile(filepath.Join(folder, "tool.1"))
+	assert.NoError(t, err, "files from the rpm should be installed")
+
+	// a deb without data.tar, a broken rpm and a file bigger than maxFileSize
+	assert.Error(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(createDeb([]archiveFile{{name: "debian-binary", content: "2.0\n"}}))), config.Bin{Cli: "tool"}, folder, "tool.deb", nil, false))
+	assert.Error(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(createRPM([]byte("broken")))), config.Bin{Cli: "tool"}, folder, "tool.rpm", nil, false))
+	big := createCpio([]archiveFile{{name: "./usr/bin/tool", content: string(bytes.Repeat([]byte("a"), 2048))}})
+	assert.Error(t, pickExtension(ctx, ioutil.NopCloser(bytes.NewReader(createRPM(big))), config.Bin{Cli: "tool"}, folder, "tool.rpm", nil, false))
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+)
+
+// pinnedDigest returns the sha256 pinned in the config for the platform or "" if nothing is pinned
+func pinnedDigest(cliName, field string, digest config.Digest, p platform) (string, error) {
+	pinned := digest.For(p.String())
+	if pinned == "" {
+		return "", nil
+	}
+	if len(pinned) != sha256.Size*2 || !hexDigest.MatchString(pinned) {
+		return "", fmt.Errorf("%v: %v %q for %v is not a sha256", cliName, field, pinned, p)
+	}
+	return pinned, nil
+}
+
+// checkPinned returns a error with both hashes if the pinned sha256 is set and differs
+func checkPinned(cliName, what, pinned, actual string) error {
+	if pinned != "" && pinned != actual {
+		return fmt.Errorf("%v: sha256 mismatch for %v, expected %v got %v", cliName, what, pinned, actual)
+	}
+	return nil
+}
+
+// satisfiesPinned returns false if what is installed don't match the pinned sha256, it's then downloaded again
+func satisfiesPinned(installed stateEntry, pinned, pinnedBinary string) bool {
+	return (pinned == "" || pinned == installed.SHA256) && (pinnedBinary == "" || pinnedBinary == installed.BinarySHA256)
+}
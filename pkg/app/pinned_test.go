@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// a pinned sha256 that don't match aborts the bin and the installed cli is left as it was
+func TestPinnedDigest(t *testing.T) {
+	binary := "#!/bin/sh\necho v2\n"
+	archive := createTarGZ(t, []archiveFile{{name: "mycli", content: binary}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive) // #nosec G104
+	}))
+	defer server.Close()
+
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	viper.Set(config.DefaultHTTPtimeoutkey, 5)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024*1024)
+	folder := newTestSaveLocation(t, "testPinned")
+
+	sum := sha256.Sum256(archive)
+	archiveSum := hex.EncodeToString(sum[:])
+	sum = sha256.Sum256([]byte(binary))
+	binarySum := hex.EncodeToString(sum[:])
+	wrongSum := strings.Repeat("0", 64)
+	required := true
+	host := hostPlatform().String()
+
+	tests := []struct {
+		binConfig config.Bin
+		expectErr bool
+	}{
+		{binConfig: config.Bin{SHA256: config.Digest{config.DigestAnyPlatform: wrongSum}}, expectErr: true},
+		{binConfig: config.Bin{SHA256: config.Digest{host: strings.ToUpper(wrongSum), config.DigestAnyPlatform: archiveSum}}, expectErr: true},
+		{binConfig: config.Bin{BinarySHA256: config.Digest{config.DigestAnyPlatform: wrongSum}}, expectErr: true},
+		{binConfig: config.Bin{SHA256: config.Digest{config.DigestAnyPlatform: "abc"}}, expectErr: true},
+		{binConfig: config.Bin{RequireChecksum: &required}, expectErr: true},
+		// a pinned sha256 is enough for requireChecksum
+		{binConfig: config.Bin{SHA256: config.Digest{host: strings.ToUpper(archiveSum)}, BinarySHA256: config.Digest{config.DigestAnyPlatform: binarySum}, RequireChecksum: &required}},
+	}
+
+	for i, tests := range tests {
+		old := fmt.Sprintf("#!/bin/sh\necho %v\n", i)
+		if err := ioutil.WriteFile(filepath.Join(folder, "mycli"), []byte(old), 0755); err != nil {
+			t.Fatalf("Unable to write old cli %v", err)
+		}
+
+		binConfig := tests.binConfig
+		binConfig.Cli = "mycli"
+		binConfig.NonGithubURL = server.URL + "/mycli.tar.gz"
+		j := job{bin: binConfig, platform: hostPlatform(), saveLocation: folder, backupLocation: folder, state: &stateFile{entries: make(map[string]stateEntry)}}
+		lock := &lockFile{entries: make(map[string]lockEntry)}
+		err := installBin(ctx, nil, server.Client(), j, lock)
+
+		installed, readErr := ioutil.ReadFile(filepath.Join(folder, "mycli"))
+		assert.NoError(t, readErr)
+		if tests.expectErr {
+			assert.Error(t, err, i)
+			assert.Equal(t, old, string(installed), i)
+			continue
+		}
+		assert.NoError(t, err, i)
+		assert.Equal(t, binary, string(installed), i)
+	}
+
+	// the stage folders is removed
+	files, err := ioutil.ReadDir(folder)
+	assert.NoError(t, err)
+	for _, file := range files {
+		assert.False(t, strings.HasPrefix(file.Name(), stagePrefix), file.Name())
+	}
+}
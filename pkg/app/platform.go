@@ -0,0 +1,273 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/spf13/viper"
+)
+
+// osAliases how different projects name the operating systems, the first alias is used in cli and urls
+var osAliases = map[string][]string{
+	"linux":   {"linux"},
+	"darwin":  {"darwin", "macos", "osx", "apple"},
+	"windows": {"windows", "win64"},
+	"freebsd": {"freebsd"},
+}
+
+// archAliases how different projects name the architectures, the first alias is used in cli and urls
+var archAliases = map[string][]string{
+	"amd64": {"amd64", "x86_64", "x64", "x86-64"},
+	"arm64": {"arm64", "aarch64"},
+	"386":   {"386", "i386", "i686"},
+	"arm":   {"armv7", "armv6", "armhf", "arm"},
+}
+
+// platform a os and arch in the same format as GOOS and GOARCH
+type platform struct {
+	os   string
+	arch string
+}
+
+// hostPlatform the platform githubbindl is running on
+func hostPlatform() platform {
+	return platform{os: runtime.GOOS, arch: runtime.GOARCH}
+}
+
+// parsePlatform parses os/arch, example: linux/amd64. A empty string gives the host platform
+func parsePlatform(s string) (platform, error) {
+	if s == "" {
+		return hostPlatform(), nil
+	}
+	parts := strings.Split(strings.ToLower(s), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return platform{}, fmt.Errorf("invalid platform %q, it should look like linux/amd64", s)
+	}
+	return platform{os: parts[0], arch: parts[1]}, nil
+}
+
+func (p platform) String() string {
+	return p.os + "/" + p.arch
+}
+
+// ext is the file extension for executables
+func (p platform) ext() string {
+	if p.os == "windows" {
+		return exeExtension
+	}
+	return ""
+}
+
+// templateData the values that can be used in match, cli, nonGithubURL, archivePath and installAs
+type templateData struct {
+	OS   string
+	Arch string
+	Ext  string
+	// Version and VersionNumber is only set in nonGithubURL when the bin have versionFrom
+	Version       string
+	VersionNumber string
+}
+
+// aliases returns the aliases for the value, the bin can override the built in table
+func aliases(value string, builtIn map[string][]string, override map[string][]string) []string {
+	if list, ok := override[value]; ok && len(list) > 0 {
+		return list
+	}
+	if list, ok := builtIn[value]; ok {
+		return list
+	}
+	return []string{value}
+}
+
+// renderBin resolves all template variables in the bin for the platform.
+// In match {{.OS}} and {{.Arch}} becomes a regex that matches all aliases, everywhere else the first alias is used.
+func renderBin(binConfig config.Bin, p platform) (config.Bin, error) {
+	osList := aliases(p.os, osAliases, binConfig.OSAliases)
+	archList := aliases(p.arch, archAliases, binConfig.ArchAliases)
+
+	// the version is found when the job runs, keep the version variables so nonGithubURL can be rendered again
+	plain := templateData{OS: osList[0], Arch: archList[0], Ext: p.ext(), Version: "{{.Version}}", VersionNumber: "{{.VersionNumber}}"}
+	regex := templateData{OS: aliasRegex(osList, osAliases), Arch: aliasRegex(archList, archAliases), Ext: regexp.QuoteMeta(p.ext())}
+
+	for _, field := range []string{binConfig.Cli, binConfig.ArchivePath, binConfig.InstallAs, binConfig.Match} {
+		if strings.Contains(field, ".Version") {
+			return binConfig, fmt.Errorf("%v: {{.Version}} can only be used in nonGithubURL", binConfig.Cli)
+		}
+	}
+	if binConfig.NonGithubURL == "" && strings.Contains(binConfig.ChecksumAsset, ".Version") {
+		return binConfig, fmt.Errorf("%v: {{.Version}} can only be used in nonGithubURL", binConfig.Cli)
+	}
+	if binConfig.VersionFrom == nil && (strings.Contains(binConfig.NonGithubURL, ".Version") || strings.Contains(binConfig.ChecksumAsset, ".Version")) {
+		return binConfig, fmt.Errorf("%v: {{.Version}} in nonGithubURL needs versionFrom", binConfig.Cli)
+	}
+
+	fields := []*string{&binConfig.Cli, &binConfig.NonGithubURL, &binConfig.ArchivePath, &binConfig.InstallAs}
+	// checksumAsset is a url next to nonGithubURL and a regex for releases
+	if binConfig.NonGithubURL != "" {
+		fields = append(fields, &binConfig.ChecksumAsset)
+	}
+	var err error
+	for _, field := range fields {
+		if *field, err = renderTemplate(*field, plain); err != nil {
+			return binConfig, fmt.Errorf("%v: %v", binConfig.Cli, err)
+		}
+	}
+	if binConfig.Match, err = renderTemplate(binConfig.Match, regex); err != nil {
+		return binConfig, fmt.Errorf("%v: %v", binConfig.Cli, err)
+	}
+	return binConfig, nil
+}
+
+// aliasRegex returns a regex group matching any of the aliases.
+// A alias that is the start of a alias for another value, like arm in arm64, have to end at a word boundary since match isn't limited to whole words.
+func aliasRegex(list []string, builtIn map[string][]string) string {
+	var quoted []string
+	for _, alias := range list {
+		q := regexp.QuoteMeta(alias)
+		if prefixOfOther(alias, list, builtIn) {
+			q += `\b`
+		}
+		quoted = append(quoted, q)
+	}
+	return "(?:" + strings.Join(quoted, "|") + ")"
+}
+
+// prefixOfOther returns true if alias is the start of any alias in builtIn that isn't in list
+func prefixOfOther(alias string, list []string, builtIn map[string][]string) bool {
+	for _, others := range builtIn {
+		for _, other := range others {
+			if other != alias && strings.HasPrefix(other, alias) && !contains(list, other) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contains returns true if the list have the value
+func contains(list []string, value string) bool {
+	for _, s := range list {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// renderTemplate executes the text as a go template, text without {{ is returned as is
+func renderTemplate(text string, data interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %v", text, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("unable to render template %q: %v", text, err)
+	}
+	return out.String(), nil
+}
+
+// job a bin rendered for a platform and where to install it
+type job struct {
+	bin            config.Bin
+	platform       platform
+	saveLocation   string
+	backupLocation string
+	state          *stateFile
+	// layoutPrefix is set when installLayout is used, saveLocation is then layoutPrefix/bin
+	layoutPrefix string
+	// matrix is true when the bin is downloaded for several platforms, each platform then gets it's own folder
+	matrix bool
+}
+
+// createJobs creates one job per bin and platform.
+// If a bin or the config have platforms each platform is installed in saveLocation/<os>_<arch>, else the target platform is installed in saveLocation.
+// With a installLayout the platforms is installed in prefix/<os>_<arch>/bin instead.
+func createJobs(configItem *config.Items, targetPlatform platform) ([]job, error) {
+	saveLocation := binLocation()
+	backupLocation := backupLocation()
+	prefix := layoutPrefix()
+
+	var jobs []job
+	for _, bin := range configItem.Bins {
+		platforms := bin.Platforms
+		if len(platforms) == 0 {
+			platforms = configItem.Platforms
+		}
+
+		if len(platforms) == 0 {
+			j, err := newJob(bin, targetPlatform, saveLocation, backupLocation, configItem)
+			if err != nil {
+				return nil, err
+			}
+			j.layoutPrefix = prefix
+			jobs = append(jobs, j)
+			continue
+		}
+
+		for _, s := range platforms {
+			p, err := parsePlatform(s)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", bin.Cli, err)
+			}
+			folder := p.os + "_" + p.arch
+			j, err := newJob(bin, p, filepath.Join(saveLocation, folder), filepath.Join(backupLocation, folder), configItem)
+			if err != nil {
+				return nil, err
+			}
+			if prefix != "" {
+				j.layoutPrefix = filepath.Join(prefix, folder)
+				j.saveLocation = filepath.Join(j.layoutPrefix, layoutBinDir)
+				if viper.GetString(config.DefaultBackupLocationKey) == "" {
+					j.backupLocation = j.saveLocation
+				}
+			}
+			j.matrix = true
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+// newJob renders the bin for the platform and fills in the global values the bin don't have
+func newJob(bin config.Bin, p platform, saveLocation, backupLocation string, configItem *config.Items) (job, error) {
+	rendered, err := renderBin(bin, p)
+	if err != nil {
+		return job{}, err
+	}
+	// use the global retention if the bin don't have it's own
+	if rendered.BackupRetention == nil {
+		rendered.BackupRetention = &configItem.BackupRetention
+	}
+	// use the global requireChecksum if the bin don't have it's own
+	if rendered.RequireChecksum == nil {
+		rendered.RequireChecksum = &configItem.RequireChecksum
+	}
+	// completion can only be generated if the cli can run here
+	if p != hostPlatform() {
+		rendered.CompletionLocation = ""
+	}
+	return job{bin: rendered, platform: p, saveLocation: saveLocation, backupLocation: backupLocation}, nil
+}
+
+// lockPlatform the platform saved in the lock file, only set for jobs that is part of a platform matrix
+func (j job) lockPlatform() string {
+	if j.matrix {
+		return j.platform.String()
+	}
+	return ""
+}
+
+// lockKey the key of the job in the lock file
+func (j job) lockKey() string {
+	return lockEntry{Cli: j.bin.InstallName(), Platform: j.lockPlatform()}.key()
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderBin(t *testing.T) {
+	bin := config.Bin{
+		Cli:          "tkn{{.Ext}}",
+		Match:        "{{.OS}}_{{.Arch}}",
+		NonGithubURL: "https://get.helm.sh/helm-v3.4.2-{{.OS}}-{{.Arch}}.tar.gz",
+	}
+
+	tests := []struct {
+		platform   string
+		asset      string
+		cli        string
+		url        string
+		matchAsset bool
+	}{
+		{platform: "linux/amd64", asset: "tkn_0.15.0_Linux_x86_64.tar.gz", cli: "tkn", url: "https://get.helm.sh/helm-v3.4.2-linux-amd64.tar.gz", matchAsset: true},
+		{platform: "windows/amd64", asset: "tkn_0.15.0_Windows_x86_64.zip", cli: "tkn.exe", url: "https://get.helm.sh/helm-v3.4.2-windows-amd64.tar.gz", matchAsset: true},
+		{platform: "darwin/arm64", asset: "tkn_0.15.0_macOS_aarch64.tar.gz", cli: "tkn", url: "https://get.helm.sh/helm-v3.4.2-darwin-arm64.tar.gz", matchAsset: true},
+		{platform: "linux/arm64", asset: "tkn_0.15.0_Linux_x86_64.tar.gz", cli: "tkn", url: "https://get.helm.sh/helm-v3.4.2-linux-arm64.tar.gz", matchAsset: false},
+		// plain arm have to end at a word boundary, else it would match arm64 as well
+		{platform: "linux/arm", asset: "tkn_0.15.0_Linux_armv7.tar.gz", cli: "tkn", url: "https://get.helm.sh/helm-v3.4.2-linux-armv7.tar.gz", matchAsset: true},
+		{platform: "linux/arm", asset: "helm-v3.4.2_linux_arm.tar.gz", cli: "tkn", url: "https://get.helm.sh/helm-v3.4.2-linux-armv7.tar.gz", matchAsset: true},
+		{platform: "linux/arm", asset: "tkn_0.15.0_Linux_arm", cli: "tkn", url: "https://get.helm.sh/helm-v3.4.2-linux-armv7.tar.gz", matchAsset: true},
+		{platform: "linux/arm", asset: "tkn_0.15.0_Linux_arm64.tar.gz", cli: "tkn", url: "https://get.helm.sh/helm-v3.4.2-linux-armv7.tar.gz", matchAsset: false},
+		{platform: "linux/arm", asset: "tkn_0.15.0_Linux_armel.tar.gz", cli: "tkn", url: "https://get.helm.sh/helm-v3.4.2-linux-armv7.tar.gz", matchAsset: false},
+	}
+
+	for _, tests := range tests {
+		p, err := parsePlatform(tests.platform)
+		if err != nil {
+			t.Errorf("Unable to parse platform %v, err: %v", tests.platform, err)
+			continue
+		}
+		rendered, err := renderBin(bin, p)
+		if err != nil {
+			t.Errorf("Unable to render bin for %v, err: %v", tests.platform, err)
+			continue
+		}
+		assert.Equal(t, tests.cli, rendered.Cli, tests.platform)
+		assert.Equal(t, tests.url, rendered.NonGithubURL, tests.platform)
+		matched, err := regexp.MatchString(strings.ToLower(rendered.Match), strings.ToLower(tests.asset))
+		assert.NoError(t, err)
+		assert.Equal(t, tests.matchAsset, matched, tests.platform)
+	}
+
+	// a bin can override the aliases, the first alias is used outside of match
+	override := config.Bin{Cli: "tool", NonGithubURL: "https://example.com/tool-{{.OS}}-{{.Arch}}", ArchAliases: map[string][]string{"amd64": {"x86_64"}}, OSAliases: map[string][]string{"darwin": {"Darwin"}}}
+	rendered, err := renderBin(override, platform{os: "darwin", arch: "amd64"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/tool-Darwin-x86_64", rendered.NonGithubURL)
+
+	_, err = renderBin(config.Bin{Cli: "{{.Unknown}}"}, hostPlatform())
+	assert.Error(t, err)
+	_, err = renderBin(config.Bin{Cli: "{{.OS"}, hostPlatform())
+	assert.Error(t, err)
+
+	for _, invalid := range []string{"linux", "linux/", "/amd64", "linux/amd64/v2"} {
+		_, err := parsePlatform(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCreateJobs(t *testing.T) {
+	defer viper.Reset()
+	viper.Set(config.DefaultSaveLocationKey, "/save")
+	viper.Set(config.DefaultBackupLocationKey, "/backup")
+
+	configItem := &config.Items{
+		Platforms: []string{"linux/amd64", "darwin/arm64"},
+		Bins: []config.Bin{
+			{Cli: "tkn-{{.OS}}", CompletionLocation: "/completion"},
+			{Cli: "helm{{.Ext}}", Platforms: []string{"windows/amd64"}},
+		},
+	}
+	jobs, err := createJobs(configItem, hostPlatform())
+	if err != nil {
+		t.Fatalf("Unable to create jobs %v", err)
+	}
+	assert.Len(t, jobs, 3)
+	assert.Equal(t, "tkn-linux", jobs[0].bin.Cli)
+	assert.Equal(t, filepath.Join("/save", "linux_amd64"), jobs[0].saveLocation)
+	assert.Equal(t, filepath.Join("/backup", "linux_amd64"), jobs[0].backupLocation)
+	assert.Equal(t, "linux/amd64/tkn-linux", jobs[0].lockKey())
+	assert.Equal(t, "tkn-darwin", jobs[1].bin.Cli)
+	assert.Equal(t, "", jobs[1].bin.CompletionLocation, "completion is only generated for the host platform")
+	// the platforms of the bin replaces the global platforms
+	assert.Equal(t, "helm.exe", jobs[2].bin.InstallName())
+	assert.Equal(t, filepath.Join("/save", "windows_amd64"), jobs[2].saveLocation)
+
+	// without platforms the target platform is installed directly in saveLocation
+	jobs, err = createJobs(&config.Items{Bins: []config.Bin{{Cli: "tkn"}}}, platform{os: "linux", arch: "arm64"})
+	if err != nil {
+		t.Fatalf("Unable to create jobs %v", err)
+	}
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, "/save", jobs[0].saveLocation)
+	assert.Equal(t, "tkn", jobs[0].lockKey())
+
+	_, err = createJobs(&config.Items{Bins: []config.Bin{{Cli: "tkn", Platforms: []string{"linux"}}}}, hostPlatform())
+	assert.Error(t, err)
+}
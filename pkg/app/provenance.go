@@ -0,0 +1,382 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v33/github"
+)
+
+// payload and predicate types that is supported
+const (
+	inTotoPayloadType = "application/vnd.in-toto+json"
+	slsaProvenanceV02 = "https://slsa.dev/provenance/v0.2"
+	slsaProvenanceV1  = "https://slsa.dev/provenance/v1"
+)
+
+var (
+	// provenanceSuffixes the default name of a attestation for a single asset, example: tool.tar.gz.intoto.jsonl
+	provenanceSuffixes = []string{".intoto.jsonl", ".sigstore.json"}
+	// provenanceFileName a attestation for the whole release like multiple.intoto.jsonl from the slsa-github-generator
+	provenanceFileName = regexp.MustCompile(`\.intoto\.jsonl$`)
+	// errNoEnvelope a sigstore bundle with a message signature, like the one from cosign sign-blob, and not a attestation
+	errNoEnvelope = errors.New("the bundle don't contain a DSSE envelope")
+)
+
+// dsseEnvelope a signed in-toto statement, the slsa-github-generator adds the certificate to the signature
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     []byte `json:"payload"`
+	Signatures  []struct {
+		KeyID string `json:"keyid"`
+		Sig   []byte `json:"sig"`
+		Cert  string `json:"cert"`
+	} `json:"signatures"`
+}
+
+// inTotoStatement the subjects that the predicate is about, identified by their digest
+type inTotoStatement struct {
+	Type          string `json:"_type"`
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	Predicate json.RawMessage `json:"predicate"`
+}
+
+// slsaPredicate the parts of SLSA provenance v0.2 and v1 that is used
+type slsaPredicate struct {
+	// v0.2
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	Invocation struct {
+		ConfigSource struct {
+			URI string `json:"uri"`
+		} `json:"configSource"`
+	} `json:"invocation"`
+	Materials []struct {
+		URI string `json:"uri"`
+	} `json:"materials"`
+	// v1
+	BuildDefinition struct {
+		ExternalParameters struct {
+			Workflow struct {
+				Repository string `json:"repository"`
+			} `json:"workflow"`
+		} `json:"externalParameters"`
+		ResolvedDependencies []struct {
+			URI string `json:"uri"`
+		} `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+	} `json:"runDetails"`
+}
+
+// signedEnvelope a envelope and the certificates and transparency log entries from the bundle it came in
+type signedEnvelope struct {
+	envelope dsseEnvelope
+	material cosignMaterial
+}
+
+// builderAndSource returns the builder ID and the source repo from a v0.2 or v1 predicate
+func (s inTotoStatement) builderAndSource() (string, string, error) {
+	var predicate slsaPredicate
+	if err := json.Unmarshal(s.Predicate, &predicate); err != nil {
+		return "", "", err
+	}
+	switch s.PredicateType {
+	case slsaProvenanceV02:
+		source := predicate.Invocation.ConfigSource.URI
+		if source == "" && len(predicate.Materials) > 0 {
+			source = predicate.Materials[0].URI
+		}
+		return predicate.Builder.ID, source, nil
+	case slsaProvenanceV1:
+		source := predicate.BuildDefinition.ExternalParameters.Workflow.Repository
+		if source == "" && len(predicate.BuildDefinition.ResolvedDependencies) > 0 {
+			source = predicate.BuildDefinition.ResolvedDependencies[0].URI
+		}
+		return predicate.RunDetails.Builder.ID, source, nil
+	}
+	return "", "", fmt.Errorf("unsupported predicate type %v", s.PredicateType)
+}
+
+// paeEncode the DSSE pre authentication encoding, this is what is signed
+func paeEncode(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// normalizeRepo makes git+https://github.com/owner/repo.git@refs/tags/v1.0.0 and github.com/owner/repo comparable
+func normalizeRepo(uri string) string {
+	uri = strings.TrimPrefix(uri, "git+")
+	if i := strings.Index(uri, "://"); i >= 0 {
+		uri = uri[i+3:]
+	}
+	if i := strings.Index(uri, "@"); i >= 0 {
+		uri = uri[:i]
+	}
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(uri, "/"), ".git"))
+}
+
+// builderMatches compares the builder ID, a expected ID without @ matches any ref of the builder
+func builderMatches(expected, builderID string) bool {
+	if expected == builderID {
+		return true
+	}
+	if strings.Contains(expected, "@") {
+		return false
+	}
+	return strings.SplitN(builderID, "@", 2)[0] == expected
+}
+
+// parseAttestations parses a .intoto.jsonl with one envelope per line or a sigstore bundle with a DSSE envelope
+func parseAttestations(data []byte) ([]signedEnvelope, error) {
+	var bundle sigstoreBundle
+	if err := json.Unmarshal(data, &bundle); err == nil && bundle.MediaType != "" {
+		if bundle.DSSEEnvelope == nil {
+			return nil, errNoEnvelope
+		}
+		certs, entries, err := bundle.verificationMaterial()
+		if err != nil {
+			return nil, err
+		}
+		return []signedEnvelope{{envelope: *bundle.DSSEEnvelope, material: cosignMaterial{certs: certs, tlogEntries: entries}}}, nil
+	}
+
+	var envelopes []signedEnvelope
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	// a envelope is a single line and can be bigger than the default buffer
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var envelope dsseEnvelope
+		if err := json.Unmarshal(line, &envelope); err != nil {
+			return nil, err
+		}
+		envelopes = append(envelopes, signedEnvelope{envelope: envelope})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(envelopes) == 0 {
+		return nil, errors.New("no attestations found")
+	}
+	return envelopes, nil
+}
+
+// fetchAttestation downloads the attestation for the asset, <asset>.intoto.jsonl is used before a attestation for the whole release.
+// A <asset>.sigstore.json without a DSSE envelope is the cosign signature of the asset and is skipped.
+func fetchAttestation(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, entry lockEntry) (*sidecar, error) {
+	provenance := binConfig.Verify.Provenance
+	attestation, err := fetchSidecar(ctx, client, httpClient, binConfig, entry, provenance.Attestation, provenanceSuffixes)
+	if err != nil || provenance.Attestation != "" {
+		return attestation, err
+	}
+	if attestation != nil {
+		if _, err := parseAttestations(attestation.data); !errors.Is(err, errNoEnvelope) {
+			return attestation, nil
+		}
+		attestation = nil
+	}
+	if entry.release == nil {
+		return nil, nil
+	}
+	for _, a := range entry.release.Assets {
+		if provenanceFileName.MatchString(a.GetName()) {
+			data, _, err := fetchAsset(ctx, client, httpClient, binConfig, lockEntry{AssetID: a.GetID(), DownloadURL: a.GetBrowserDownloadURL()})
+			if err != nil {
+				return nil, fmt.Errorf("%v: unable to download %v: %v", binConfig.Cli, a.GetName(), err)
+			}
+			return &sidecar{name: a.GetName(), data: data}, nil
+		}
+	}
+	return nil, nil
+}
+
+// verifyProvenance verifies that a signed SLSA provenance have the asset as subject and that it's built by the expected builder from the expected source repo
+func verifyProvenance(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, entry lockEntry, data blob) error {
+	log := logr.FromContext(ctx)
+	provenance := binConfig.Verify.Provenance
+
+	switch {
+	case provenance.Key == "" && provenance.TrustRoot == "", provenance.Key != "" && provenance.TrustRoot != "":
+		return fmt.Errorf("%v: provenance needs one of key and trustRoot", binConfig.Cli)
+	case provenance.Key == "" && provenance.Issuer == "":
+		return fmt.Errorf("%v: provenance needs a issuer together with trustRoot", binConfig.Cli)
+	case provenance.BuilderID == "":
+		return fmt.Errorf("%v: provenance needs a builderID", binConfig.Cli)
+	}
+	sourceRepo := provenance.SourceRepo
+	if sourceRepo == "" {
+		if binConfig.Owner == "" || binConfig.Repo == "" {
+			return fmt.Errorf("%v: provenance needs a sourceRepo when owner and repo isn't set", binConfig.Cli)
+		}
+		sourceRepo = "github.com/" + binConfig.Owner + "/" + binConfig.Repo
+	}
+
+	var publicKey crypto.PublicKey
+	var root trustedRoot
+	var err error
+	if provenance.Key != "" {
+		if publicKey, err = readPublicKey(expandHome(provenance.Key)); err != nil {
+			return fmt.Errorf("%v: unable to read provenance key %v: %v", binConfig.Cli, provenance.Key, err)
+		}
+	} else if root, err = readTrustRoot(expandHome(provenance.TrustRoot)); err != nil {
+		return fmt.Errorf("%v: unable to read trustRoot %v: %v", binConfig.Cli, provenance.TrustRoot, err)
+	}
+
+	attestation, err := fetchAttestation(ctx, client, httpClient, binConfig, entry)
+	if err != nil {
+		return err
+	}
+	if attestation == nil {
+		return fmt.Errorf("%v: unable to find a provenance attestation for %v", binConfig.Cli, entry.AssetName)
+	}
+	envelopes, err := parseAttestations(attestation.data)
+	if err != nil {
+		return fmt.Errorf("%v: unable to parse attestation %v: %v", binConfig.Cli, attestation.name, err)
+	}
+
+	sum, err := blobSHA256(data)
+	if err != nil {
+		return err
+	}
+	digest := hex.EncodeToString(sum)
+	// a release wide attestation can have more than one envelope about the asset, one that verifies is enough
+	var lastErr error
+	for _, signed := range envelopes {
+		envelope := signed.envelope
+		if envelope.PayloadType != inTotoPayloadType {
+			continue
+		}
+		var statement inTotoStatement
+		if err := json.Unmarshal(envelope.Payload, &statement); err != nil {
+			lastErr = fmt.Errorf("%v: invalid in-toto statement in %v: %v", binConfig.Cli, attestation.name, err)
+			continue
+		}
+		if !hasSubject(statement, digest) {
+			continue
+		}
+
+		builderID, source, err := statement.builderAndSource()
+		if err != nil {
+			lastErr = fmt.Errorf("%v: %v in %v", binConfig.Cli, err, attestation.name)
+			continue
+		}
+		if publicKey == nil && len(signed.material.tlogEntries) == 0 {
+			// a .intoto.jsonl don't carry the log entry like a bundle, it's looked up in the log of the trust root
+			sum := sha256.Sum256(envelope.Payload)
+			if signed.material.tlogEntries, err = root.searchEntries(ctx, httpClient, binConfig.Cli, sum[:]); err != nil {
+				lastErr = fmt.Errorf("%v: unable to look up the transparency log entry of %v: %v", binConfig.Cli, attestation.name, err)
+				continue
+			}
+		}
+		if err := verifyEnvelope(signed, publicKey, root, provenance, builderID); err != nil {
+			lastErr = fmt.Errorf("%v: provenance %v is not valid: %v", binConfig.Cli, attestation.name, err)
+			continue
+		}
+		if !builderMatches(provenance.BuilderID, builderID) {
+			lastErr = fmt.Errorf("%v: %v is built by %v, expected %v", binConfig.Cli, entry.AssetName, builderID, provenance.BuilderID)
+			continue
+		}
+		if normalizeRepo(source) != normalizeRepo(sourceRepo) {
+			lastErr = fmt.Errorf("%v: %v is built from %v, expected %v", binConfig.Cli, entry.AssetName, source, sourceRepo)
+			continue
+		}
+		log.Info("Verified provenance", "cli", binConfig.Cli, "asset", entry.AssetName, "builder", builderID, "source", source)
+		return nil
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	return fmt.Errorf("%v: %v is not a subject in the provenance %v", binConfig.Cli, entry.AssetName, attestation.name)
+}
+
+// hasSubject returns true if any subject in the statement have the sha256 digest
+func hasSubject(statement inTotoStatement, digest string) bool {
+	for _, subject := range statement.Subject {
+		if strings.EqualFold(subject.Digest["sha256"], digest) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyEnvelope checks that any of the signatures is valid.
+// Keyless the certificate have to chain up to the trust root at the verified log time and be issued to the builder that is claimed in the provenance.
+func verifyEnvelope(signed signedEnvelope, publicKey crypto.PublicKey, root trustedRoot, provenance *config.Provenance, builderID string) error {
+	envelope := signed.envelope
+	if len(envelope.Signatures) == 0 {
+		return errors.New("the envelope isn't signed")
+	}
+	pae := paeEncode(envelope.PayloadType, envelope.Payload)
+
+	var lastErr error
+	for _, signature := range envelope.Signatures {
+		key := publicKey
+		if key == nil {
+			certs := signed.material.certs
+			if signature.Cert != "" {
+				var err error
+				if certs, err = parseCertificates([]byte(signature.Cert)); err != nil {
+					lastErr = err
+					continue
+				}
+			}
+			if len(certs) == 0 {
+				lastErr = errors.New("no certificate found, needed for keyless verification")
+				continue
+			}
+			if err := verifyBuilderCertificate(root, provenance, certs, signed.material.tlogEntries, envelope.Payload, signature.Sig, builderID); err != nil {
+				lastErr = err
+				continue
+			}
+			key = certs[0].PublicKey
+		}
+		if err := verifySignature(key, pae, signature.Sig); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// verifyBuilderCertificate checks the certificate chain at the time the log entry of the payload and signature is verified to be logged,
+// and that the certificate is issued to the builder by the issuer
+func verifyBuilderCertificate(root trustedRoot, provenance *config.Provenance, certs []*x509.Certificate, entries []tlogEntry, payload, signature []byte, builderID string) error {
+	sum := sha256.Sum256(payload)
+	signedAt, err := root.logTime(entries, sum[:], signature, certs[0])
+	if err != nil {
+		return err
+	}
+	if err := verifyCertificateChain(root, certs, signedAt); err != nil {
+		return err
+	}
+	identities := certificateIdentities(certs[0])
+	if !identityMatches(builderID, nil, identities) {
+		return fmt.Errorf("the certificate is issued to %v and not the builder %v", strings.Join(identities, ", "), builderID)
+	}
+	return verifyIssuer(certs[0], provenance.Issuer)
+}
@@ -0,0 +1,272 @@
+package app
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/google/go-github/v33/github"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestEnvelope returns a signed DSSE envelope with a SLSA provenance for the data, cert is added to the signature if set
+func newTestEnvelope(t *testing.T, key *ecdsa.PrivateKey, cert *x509.Certificate, predicateType, digest string, predicate interface{}) dsseEnvelope {
+	statement, err := json.Marshal(map[string]interface{}{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": predicateType,
+		"subject":       []map[string]interface{}{{"name": "tool.tar.gz", "digest": map[string]string{"sha256": digest}}},
+		"predicate":     predicate,
+	})
+	if err != nil {
+		t.Fatalf("Unable to marshal statement %v", err)
+	}
+	envelope := dsseEnvelope{PayloadType: inTotoPayloadType, Payload: statement}
+	envelope.Signatures = append(envelope.Signatures, struct {
+		KeyID string `json:"keyid"`
+		Sig   []byte `json:"sig"`
+		Cert  string `json:"cert"`
+	}{Sig: signBlob(t, key, paeEncode(inTotoPayloadType, statement))})
+	if cert != nil {
+		envelope.Signatures[0].Cert = string(pemCert(cert))
+	}
+	return envelope
+}
+
+// dsseBody the log entry of a DSSE envelope signed with the certificate
+func dsseBody(envelope dsseEnvelope, cert *x509.Certificate) interface{} {
+	sum := sha256.Sum256(envelope.Payload)
+	var signatures []map[string]interface{}
+	for _, signature := range envelope.Signatures {
+		signatures = append(signatures, map[string]interface{}{"signature": signature.Sig, "verifier": pemCert(cert)})
+	}
+	return map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "dsse",
+		"spec": map[string]interface{}{
+			"payloadHash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(sum[:])},
+			"signatures":  signatures,
+		},
+	}
+}
+
+func TestVerifyProvenance(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	viper.Set(config.DefaultHTTPtimeoutkey, 5)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024*1024)
+	folder := newTestSaveLocation(t, "testProvenance")
+
+	data := []byte("the asset")
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	builder := "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml@refs/tags/v1.9.0"
+	issuer := "https://token.actions.githubusercontent.com"
+
+	ca, caKey := newTestCA(t, "sigstore")
+	leaf, leafKey := newTestLeaf(t, ca, caKey, builder, issuer)
+	impostor, impostorKey := newTestLeaf(t, ca, caKey, "https://github.com/someone/else/.github/workflows/release.yml@refs/heads/main", issuer)
+	_, logKey := newTestCA(t, "rekor")
+
+	var files map[string]string
+	// the transparency log entries by the hash they're searched for
+	var tlog map[string]tlogEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/index/retrieve":
+			var query struct {
+				Hash string `json:"hash"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&query); err != nil || r.Method != http.MethodPost {
+				http.Error(w, "invalid query", http.StatusBadRequest)
+				return
+			}
+			uuids := []string{}
+			if _, ok := tlog[query.Hash]; ok {
+				uuids = append(uuids, strings.TrimPrefix(query.Hash, "sha256:"))
+			}
+			json.NewEncoder(w).Encode(uuids) // #nosec G104
+		case strings.HasPrefix(r.URL.Path, "/api/v1/log/entries/"):
+			entry, ok := tlog["sha256:"+strings.TrimPrefix(r.URL.Path, "/api/v1/log/entries/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{ // #nosec G104
+				"uuid": map[string]interface{}{
+					"body":           entry.body,
+					"integratedTime": entry.integratedTime,
+					"logID":          hex.EncodeToString(entry.logID),
+					"logIndex":       entry.logIndex,
+					"verification":   map[string]interface{}{"signedEntryTimestamp": entry.signedEntryTimestamp},
+				},
+			})
+		default:
+			content, ok := files[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(content)) // #nosec G104
+		}
+	}))
+	defer server.Close()
+
+	trustRoot := filepath.Join(folder, "trusted_root.json")
+	writeTestTrustRoot(t, trustRoot, server.URL, ca, logKey)
+	keyFile := filepath.Join(folder, "provenance.pub")
+	publicKey, err := x509.MarshalPKIXPublicKey(&leafKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Unable to marshal public key %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}), 0644); err != nil {
+		t.Fatalf("Unable to write key %v", err)
+	}
+
+	v1 := map[string]interface{}{
+		"buildDefinition": map[string]interface{}{"externalParameters": map[string]interface{}{"workflow": map[string]string{"repository": "https://github.com/tektoncd/cli"}}},
+		"runDetails":      map[string]interface{}{"builder": map[string]string{"id": builder}},
+	}
+	v02 := map[string]interface{}{
+		"builder":    map[string]string{"id": builder},
+		"invocation": map[string]interface{}{"configSource": map[string]string{"uri": "git+https://github.com/tektoncd/cli@refs/tags/v0.15.0"}},
+	}
+	jsonl := func(envelopes ...dsseEnvelope) string {
+		var out string
+		for _, envelope := range envelopes {
+			line, err := json.Marshal(envelope)
+			if err != nil {
+				t.Fatalf("Unable to marshal envelope %v", err)
+			}
+			out += string(line) + "\n"
+		}
+		return out
+	}
+
+	// keyless the log entry comes in the bundle, or from the log for a .intoto.jsonl
+	newBundle := func(envelope dsseEnvelope, cert *x509.Certificate, entries ...tlogEntry) string {
+		tlogEntries := []map[string]interface{}{}
+		for _, entry := range entries {
+			tlogEntries = append(tlogEntries, sigstoreTlogEntry(entry))
+		}
+		bundle, _ := json.Marshal(map[string]interface{}{
+			"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json",
+			"verificationMaterial": map[string]interface{}{
+				"certificate": map[string]interface{}{"rawBytes": cert.Raw},
+				"tlogEntries": tlogEntries,
+			},
+			"dsseEnvelope": envelope,
+		})
+		return string(bundle)
+	}
+	logged := func(envelope dsseEnvelope, cert *x509.Certificate) string {
+		return newBundle(envelope, cert, newTestTlogEntry(t, logKey, dsseBody(envelope, cert)))
+	}
+	inLog := func(envelope dsseEnvelope, entry tlogEntry) map[string]tlogEntry {
+		sum := sha256.Sum256(envelope.Payload)
+		return map[string]tlogEntry{"sha256:" + hex.EncodeToString(sum[:]): entry}
+	}
+	// the bundle from cosign sign-blob, it's a signature and not a attestation
+	messageSignature, _ := json.Marshal(map[string]interface{}{
+		"mediaType":            "application/vnd.dev.sigstore.bundle.v0.3+json",
+		"verificationMaterial": map[string]interface{}{"certificate": map[string]interface{}{"rawBytes": leaf.Raw}},
+		"messageSignature":     map[string]interface{}{"messageDigest": map[string]interface{}{"algorithm": "SHA2_256", "digest": sum[:]}, "signature": signBlob(t, leafKey, data)},
+	})
+
+	valid := newTestEnvelope(t, leafKey, nil, slsaProvenanceV1, digest, v1)
+	other := newTestEnvelope(t, leafKey, nil, slsaProvenanceV1, hex.EncodeToString(make([]byte, 32)), v1)
+	// the payload of valid with the signature of other
+	tampered := newTestEnvelope(t, leafKey, nil, slsaProvenanceV1, digest, v1)
+	tampered.Signatures = other.Signatures
+	keyed := newTestEnvelope(t, leafKey, nil, slsaProvenanceV02, digest, v02)
+	keyedTampered := newTestEnvelope(t, leafKey, nil, slsaProvenanceV02, digest, v02)
+	keyedTampered.Signatures = other.Signatures
+	withCert := newTestEnvelope(t, leafKey, leaf, slsaProvenanceV1, digest, v1)
+
+	keyless := config.Provenance{TrustRoot: trustRoot, Issuer: issuer, BuilderID: builder}
+
+	tests := []struct {
+		name       string
+		provenance config.Provenance
+		owner      string
+		files      map[string]string
+		tlog       map[string]tlogEntry
+		assets     []string
+		expectErr  bool
+	}{
+		{name: "keyless v1", provenance: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": logged(valid, leaf)}},
+		{name: "key v0.2", provenance: config.Provenance{Key: keyFile, BuilderID: builder}, files: map[string]string{"/tool.tar.gz.intoto.jsonl": jsonl(other, keyed)}},
+		{name: "invalid envelope before a valid one", provenance: config.Provenance{Key: keyFile, BuilderID: builder}, files: map[string]string{"/tool.tar.gz.intoto.jsonl": jsonl(keyedTampered, keyed)}},
+		{name: "only invalid envelopes", provenance: config.Provenance{Key: keyFile, BuilderID: builder}, files: map[string]string{"/tool.tar.gz.intoto.jsonl": jsonl(keyedTampered, other)}, expectErr: true},
+		{name: "builder without ref", provenance: config.Provenance{TrustRoot: trustRoot, Issuer: issuer, BuilderID: "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml"}, files: map[string]string{"/tool.tar.gz.sigstore.json": logged(valid, leaf)}},
+		{name: "certificate in the envelope", provenance: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": logged(newTestEnvelope(t, leafKey, leaf, slsaProvenanceV1, digest, v1), leaf)}},
+		{name: "keyless jsonl with the entry in the log", provenance: keyless, files: map[string]string{"/tool.tar.gz.intoto.jsonl": jsonl(withCert)}, tlog: inLog(withCert, newTestTlogEntry(t, logKey, dsseBody(withCert, leaf)))},
+		{name: "keyless jsonl without log entry", provenance: keyless, files: map[string]string{"/tool.tar.gz.intoto.jsonl": jsonl(withCert)}, expectErr: true},
+		{name: "keyless jsonl with a log entry for another payload", provenance: keyless, files: map[string]string{"/tool.tar.gz.intoto.jsonl": jsonl(withCert)}, tlog: inLog(withCert, newTestTlogEntry(t, logKey, dsseBody(other, leaf))), expectErr: true},
+		{name: "signature bundle next to the release attestation", provenance: config.Provenance{Key: keyFile, BuilderID: builder}, files: map[string]string{"/tool.tar.gz.sigstore.json": string(messageSignature), "/multiple.intoto.jsonl": jsonl(other, keyed)}, assets: []string{"tool.tar.gz", "tool.tar.gz.sigstore.json", "multiple.intoto.jsonl"}},
+		{name: "signature bundle without a release attestation", provenance: config.Provenance{Key: keyFile, BuilderID: builder}, files: map[string]string{"/tool.tar.gz.sigstore.json": string(messageSignature)}, assets: []string{"tool.tar.gz", "tool.tar.gz.sigstore.json"}, expectErr: true},
+		{name: "bundle without log entry", provenance: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": newBundle(valid, leaf)}, expectErr: true},
+		{name: "log entry for another payload", provenance: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": newBundle(valid, leaf, newTestTlogEntry(t, logKey, dsseBody(other, leaf)))}, expectErr: true},
+		{name: "other builder", provenance: config.Provenance{TrustRoot: trustRoot, Issuer: issuer, BuilderID: "https://github.com/example/builder/.github/workflows/build.yml"}, files: map[string]string{"/tool.tar.gz.sigstore.json": logged(valid, leaf)}, expectErr: true},
+		{name: "other source repo", provenance: keyless, owner: "example", files: map[string]string{"/tool.tar.gz.sigstore.json": logged(valid, leaf)}, expectErr: true},
+		{name: "not a subject", provenance: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": logged(other, leaf)}, expectErr: true},
+		{name: "tampered payload", provenance: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": logged(tampered, leaf)}, expectErr: true},
+		{name: "certificate not issued to the builder", provenance: keyless, files: map[string]string{"/tool.tar.gz.sigstore.json": logged(newTestEnvelope(t, impostorKey, nil, slsaProvenanceV1, digest, v1), impostor)}, expectErr: true},
+		{name: "wrong issuer", provenance: config.Provenance{TrustRoot: trustRoot, Issuer: "https://accounts.google.com", BuilderID: builder}, files: map[string]string{"/tool.tar.gz.sigstore.json": logged(valid, leaf)}, expectErr: true},
+		{name: "missing issuer", provenance: config.Provenance{TrustRoot: trustRoot, BuilderID: builder}, files: map[string]string{"/tool.tar.gz.sigstore.json": logged(valid, leaf)}, expectErr: true},
+		{name: "missing attestation", provenance: keyless, files: map[string]string{}, expectErr: true},
+		{name: "missing builderID", provenance: config.Provenance{TrustRoot: trustRoot, Issuer: issuer}, files: map[string]string{"/tool.tar.gz.sigstore.json": logged(valid, leaf)}, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		files = tests.files
+		tlog = tests.tlog
+		provenance := tests.provenance
+		owner := tests.owner
+		if owner == "" {
+			owner = "tektoncd"
+		}
+		binConfig := config.Bin{Cli: "tool", Owner: owner, Repo: "cli", Verify: &config.Verify{Provenance: &provenance}}
+		entry := lockEntry{AssetName: "tool.tar.gz", DownloadURL: server.URL + "/tool.tar.gz"}
+		if tests.assets != nil {
+			entry.release = &github.RepositoryRelease{TagName: github.String("v0.15.0")}
+			for _, name := range tests.assets {
+				entry.release.Assets = append(entry.release.Assets, &github.ReleaseAsset{Name: github.String(name), BrowserDownloadURL: github.String(server.URL + "/" + name)})
+			}
+		}
+		err := verifyProvenance(ctx, nil, server.Client(), binConfig, entry, bytesBlob(data))
+		if tests.expectErr {
+			assert.Error(t, err, tests.name)
+			continue
+		}
+		assert.NoError(t, err, tests.name)
+	}
+}
+
+func TestNormalizeRepo(t *testing.T) {
+	tests := []struct {
+		uri       string
+		expectOut string
+	}{
+		{uri: "git+https://github.com/tektoncd/cli@refs/tags/v0.15.0", expectOut: "github.com/tektoncd/cli"},
+		{uri: "https://github.com/TektonCD/cli.git", expectOut: "github.com/tektoncd/cli"},
+		{uri: "github.com/tektoncd/cli/", expectOut: "github.com/tektoncd/cli"},
+	}
+
+	for _, tests := range tests {
+		assert.Equal(t, tests.expectOut, normalizeRepo(tests.uri), tests.uri)
+	}
+}
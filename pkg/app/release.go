@@ -0,0 +1,269 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v33/github"
+)
+
+// releasesPerPage is the max amount of releases that the GitHub API returns per page
+const releasesPerPage = 100
+
+// release channels, stable is used if no channel is set
+const (
+	channelStable     = "stable"
+	channelPrerelease = "prerelease"
+	channelAny        = "any"
+)
+
+// resolveRelease finds the release to download.
+// tag gives a exact release, version, tagPattern, channel and excludeTags pages through all releases and picks the highest that matches
+// and if nothing is set the latest release is used.
+func resolveRelease(ctx context.Context, client *github.Client, binConfig config.Bin) (*github.RepositoryRelease, error) {
+	log := logr.FromContext(ctx)
+
+	// response gives information about rate limit etc. I assume I will get an error if i go over my rate limit
+	if binConfig.Tag != "" {
+		resp, _, err := client.Repositories.GetReleaseByTag(ctx, binConfig.Owner, binConfig.Repo, binConfig.Tag)
+		return resp, err
+	}
+
+	if binConfig.Version == "" && binConfig.TagPrefix == "" && binConfig.TagPattern == "" && binConfig.Channel == "" && len(binConfig.ExcludeTags) == 0 {
+		resp, _, err := client.Repositories.GetLatestRelease(ctx, binConfig.Owner, binConfig.Repo)
+		return resp, err
+	}
+
+	filter, err := newTagFilter(binConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *github.RepositoryRelease
+	var bestVersion semver
+	// if no tag can be parsed as a version the newest release is used, GitHub lists the newest first
+	var newest *github.RepositoryRelease
+
+	opt := &github.ListOptions{PerPage: releasesPerPage}
+	for {
+		releases, resp, err := client.Repositories.ListReleases(ctx, binConfig.Owner, binConfig.Repo, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, release := range releases {
+			if !allowedByChannel(release, filter.channel) {
+				continue
+			}
+			v, matched, parsed := filter.version(release.GetTagName())
+			if !matched {
+				continue
+			}
+			if !parsed {
+				if newest == nil && binConfig.Version == "" && binConfig.TagPrefix == "" {
+					newest = release
+				}
+				log.V(1).Info("Ignoring release, unable to parse tag as a version", "cli", binConfig.Cli, "tag", release.GetTagName())
+				continue
+			}
+			if !filter.constraint.check(v) {
+				continue
+			}
+			if best == nil || v.compare(bestVersion) > 0 {
+				best = release
+				bestVersion = v
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	if best == nil {
+		best = newest
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%v: unable to find a release in %v/%v matching version %q in channel %v", binConfig.Cli, binConfig.Owner, binConfig.Repo, binConfig.Version, filter.channel)
+	}
+	log.Info("Resolved release", "cli", binConfig.Cli, "tag", best.GetTagName(), "version", binConfig.Version, "channel", filter.channel)
+	return best, nil
+}
+
+// tagFilter the version, tagPrefix, tagPattern, channel and excludeTags of a bin
+type tagFilter struct {
+	constraint  constraint
+	channel     string
+	tagPrefix   string
+	tagPattern  *regexp.Regexp
+	excludeTags []*regexp.Regexp
+}
+
+func newTagFilter(binConfig config.Bin) (tagFilter, error) {
+	filter := tagFilter{channel: binConfig.Channel, tagPrefix: binConfig.TagPrefix}
+	var err error
+	filter.constraint, err = parseConstraint("*")
+	if binConfig.Version != "" {
+		filter.constraint, err = parseConstraint(binConfig.Version)
+	}
+	if err != nil {
+		return filter, err
+	}
+
+	if filter.channel == "" {
+		filter.channel = channelStable
+	}
+	switch filter.channel {
+	case channelStable:
+	case channelPrerelease, channelAny:
+		filter.constraint.allowPrerelease = true
+	default:
+		return filter, fmt.Errorf("%v: unknown channel %q, supported channels are %v, %v and %v", binConfig.Cli, filter.channel, channelStable, channelPrerelease, channelAny)
+	}
+
+	for _, pattern := range binConfig.ExcludeTags {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("%v: invalid excludeTags pattern %q: %v", binConfig.Cli, pattern, err)
+		}
+		filter.excludeTags = append(filter.excludeTags, r)
+	}
+
+	if binConfig.TagPattern != "" {
+		filter.tagPattern, err = regexp.Compile(binConfig.TagPattern)
+		if err != nil {
+			return filter, fmt.Errorf("%v: invalid tagPattern %q: %v", binConfig.Cli, binConfig.TagPattern, err)
+		}
+	}
+	return filter, nil
+}
+
+// version returns the version in the tag. matched is false if the tag is excluded or don't match tagPattern,
+// parsed is false if the tag can't be parsed as a version.
+func (f tagFilter) version(tag string) (v semver, matched bool, parsed bool) {
+	if matchesAny(tag, f.excludeTags) {
+		return v, false, false
+	}
+	versionPart := tag
+	if f.tagPattern != nil {
+		if versionPart, matched = extractTagVersion(tag, f.tagPattern); !matched {
+			return v, false, false
+		}
+	}
+	v, parsed = parseVersion(versionPart, f.tagPrefix)
+	return v, true, parsed
+}
+
+// allowedByChannel stable only allows full releases, prerelease also allows releases marked as prerelease and any allows drafts as well.
+// Drafts are only visible if the githubAPIkey have push access to the repo.
+func allowedByChannel(release *github.RepositoryRelease, channel string) bool {
+	switch {
+	case release.GetDraft():
+		return channel == channelAny
+	case release.GetPrerelease():
+		return channel != channelStable
+	}
+	return true
+}
+
+// extractTagVersion returns the version part of a tag that matches the tagPattern.
+// The version is taken from a group named version, the first group or the whole match in that order.
+func extractTagVersion(tag string, tagPattern *regexp.Regexp) (string, bool) {
+	match := tagPattern.FindStringSubmatch(tag)
+	if match == nil {
+		return "", false
+	}
+	for i, name := range tagPattern.SubexpNames() {
+		if name == "version" {
+			return match[i], true
+		}
+	}
+	if len(match) > 1 {
+		return match[1], true
+	}
+	return match[0], true
+}
+
+// matchesAny returns true if s matches any of the patterns, used for excludeTags and exclude
+func matchesAny(s string, patterns []*regexp.Regexp) bool {
+	for _, r := range patterns {
+		if r.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAsset finds what to download for the bin and platform without downloading it
+func resolveAsset(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, p platform) (lockEntry, error) {
+	log := logr.FromContext(ctx)
+
+	if binConfig.NonGithubURL != "" {
+		downloadURL := binConfig.NonGithubURL
+		var version string
+		if binConfig.VersionFrom != nil {
+			var err error
+			version, err = discoverVersion(ctx, client, httpClient, binConfig)
+			if err != nil {
+				return lockEntry{}, err
+			}
+			downloadURL, err = renderVersionURL(binConfig.Cli, binConfig.NonGithubURL, version)
+			if err != nil {
+				return lockEntry{}, err
+			}
+		}
+		u, err := url.Parse(downloadURL)
+		if err != nil {
+			return lockEntry{}, err
+		}
+		entry := lockEntry{Cli: binConfig.InstallName(), Tag: version, AssetName: path.Base(u.Path), DownloadURL: downloadURL}
+
+		// there is no release to look in so checksumAsset is the url of the checksum file
+		if binConfig.ChecksumAsset != "" {
+			checksumURL, err := renderVersionURL(binConfig.Cli, binConfig.ChecksumAsset, version)
+			if err != nil {
+				return lockEntry{}, err
+			}
+			u, err := url.Parse(checksumURL)
+			if err != nil {
+				return lockEntry{}, err
+			}
+			entry.checksumFile = &releaseFile{name: path.Base(u.Path), url: checksumURL}
+		}
+		return entry, nil
+	}
+
+	release, err := resolveRelease(ctx, client, binConfig)
+	if err != nil {
+		return lockEntry{}, err
+	}
+
+	asset, err := selectAsset(binConfig, release, p)
+	if err != nil {
+		return lockEntry{}, err
+	}
+	log.Info("Selected asset", "cli", binConfig.Cli, "asset", asset.GetName())
+
+	checksumFile, err := findChecksumFile(binConfig, release, asset)
+	if err != nil {
+		return lockEntry{}, err
+	}
+
+	return lockEntry{
+		Cli:          binConfig.InstallName(),
+		Tag:          release.GetTagName(),
+		AssetName:    asset.GetName(),
+		AssetID:      asset.GetID(),
+		DownloadURL:  asset.GetBrowserDownloadURL(),
+		Size:         int64(asset.GetSize()),
+		checksumFile: checksumFile,
+		release:      release,
+	}, nil
+}
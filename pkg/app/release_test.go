@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/google/go-github/v33/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestGitHubClient returns a github client that talks to a local server with the provided handler, remember to close the server
+func newTestGitHubClient(t *testing.T, handler http.Handler) (*github.Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("Unable to parse test server url %v", err)
+	}
+	client.BaseURL = baseURL
+	return client, server
+}
+
+func TestResolveRelease(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/tektoncd/cli/releases", func(w http.ResponseWriter, r *http.Request) {
+		// two pages to make sure that all releases are looked at
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `[{"tag_name": "v1.2.0"}, {"tag_name": "v0.9.0"}]`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%v/repos/tektoncd/cli/releases?page=2>; rel="next"`, r.Host))
+		fmt.Fprint(w, `[{"tag_name": "v2.0.0"}, {"tag_name": "v1.5.0", "draft": true}, {"tag_name": "v1.4.1-rc.1"}, {"tag_name": "nightly"}, {"tag_name": "v1.3.0"}, {"tag_name": "v1.4.0", "prerelease": true}]`)
+	})
+	mux.HandleFunc("/repos/tektoncd/cli/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v2.0.0"}`)
+	})
+	client, server := newTestGitHubClient(t, mux)
+	defer server.Close()
+
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+
+	tests := []struct {
+		version     string
+		channel     string
+		excludeTags []string
+		expectOut   string
+		expectErr   bool
+	}{
+		{version: "", expectOut: "v2.0.0"},
+		{version: ">=1.0.0, <2.0.0", expectOut: "v1.3.0"},
+		{version: "~1.2", expectOut: "v1.2.0"},
+		{version: "<1.0.0", expectOut: "v0.9.0"},
+		{version: ">=3.0.0", expectErr: true},
+		{version: "<2.0.0", channel: "stable", expectOut: "v1.3.0"},
+		{version: "<2.0.0", channel: "prerelease", expectOut: "v1.4.1-rc.1"},
+		{version: "<2.0.0", channel: "prerelease", excludeTags: []string{"-rc"}, expectOut: "v1.4.0"},
+		{version: "<2.0.0", channel: "any", expectOut: "v1.5.0"},
+		{excludeTags: []string{"^v2"}, expectOut: "v1.3.0"},
+		{channel: "nightly", expectErr: true},
+		{excludeTags: []string{"("}, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		release, err := resolveRelease(ctx, client, config.Bin{Cli: "tkn", Owner: "tektoncd", Repo: "cli", Version: tests.version, Channel: tests.channel, ExcludeTags: tests.excludeTags})
+		if tests.expectErr {
+			assert.Error(t, err, tests.version)
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unable to resolve release for version %v, err: %v", tests.version, err)
+			continue
+		}
+		assert.Equal(t, tests.expectOut, release.GetTagName(), tests.version)
+	}
+}
+
+func TestResolveReleaseTagPattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/kubernetes-sigs/kustomize/releases", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"tag_name": "api/v0.13.0"}, {"tag_name": "kyaml/v0.14.0"}, {"tag_name": "kustomize/v4.5.7"}, {"tag_name": "kustomize/v5.0.0"}, {"tag_name": "cmd/config/v0.11.0"}]`)
+	})
+	client, server := newTestGitHubClient(t, mux)
+	defer server.Close()
+
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+
+	tests := []struct {
+		tagPattern string
+		version    string
+		expectOut  string
+		expectErr  bool
+	}{
+		{tagPattern: `^kustomize/(v[\d.]+)$`, expectOut: "kustomize/v5.0.0"},
+		{tagPattern: `^kustomize/(?P<version>.*)$`, version: "<5.0.0", expectOut: "kustomize/v4.5.7"},
+		{tagPattern: `^api/`, expectOut: "api/v0.13.0"},
+		{tagPattern: `^kyaml/`, version: ">=1.0.0", expectErr: true},
+		{tagPattern: `^kustomize/(`, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		release, err := resolveRelease(ctx, client, config.Bin{Cli: "kustomize", Owner: "kubernetes-sigs", Repo: "kustomize", TagPattern: tests.tagPattern, Version: tests.version})
+		if tests.expectErr {
+			assert.Error(t, err, tests.tagPattern)
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unable to resolve release for tagPattern %v, err: %v", tests.tagPattern, err)
+			continue
+		}
+		assert.Equal(t, tests.expectOut, release.GetTagName(), tests.tagPattern)
+	}
+}
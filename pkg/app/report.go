@@ -0,0 +1,166 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/util"
+	"github.com/google/go-github/v33/github"
+)
+
+// report statuses
+const (
+	statusUpToDate     = "up to date"
+	statusOutdated     = "outdated"
+	statusNotInstalled = "not installed"
+	statusModified     = "modified"
+	statusError        = "error"
+)
+
+// report output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// reportEntry the installed version of a cli compared to the available
+type reportEntry struct {
+	Cli       string `json:"cli"`
+	Platform  string `json:"platform"`
+	Installed string `json:"installed"`
+	Available string `json:"available"`
+	Asset     string `json:"asset,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// the result of check when not everything is up to date, a scheduled job can use the exit code
+var (
+	// ErrUpdatesAvailable any bin is outdated, not installed or modified
+	ErrUpdatesAvailable = errors.New("updates are available")
+	// ErrCheckFailed any bin couldn't be checked, the report is incomplete so this is returned even if there is updates
+	ErrCheckFailed = errors.New("unable to check all bins")
+)
+
+// report all bins that only should be reported, the mutex is needed since all bins are checked at the same time
+type report struct {
+	Bins []reportEntry `json:"bins"`
+
+	mu sync.Mutex
+}
+
+func (r *report) add(entry reportEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Bins = append(r.Bins, entry)
+}
+
+// result returns ErrCheckFailed if any bin couldn't be checked, ErrUpdatesAvailable if any bin needs to be installed and nil if everything is up to date
+func (r *report) result() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result error
+	for _, entry := range r.Bins {
+		switch entry.Status {
+		case statusError:
+			return ErrCheckFailed
+		case statusOutdated, statusNotInstalled, statusModified:
+			result = ErrUpdatesAvailable
+		}
+	}
+	return result
+}
+
+// reportBin is used instead of downloadBin for the check command and bins with download: false.
+// A bin that can't be resolved is reported with the error, the other bins is still checked.
+func reportBin(ctx context.Context, wg *sync.WaitGroup, client *github.Client, httpClient *http.Client, j job, r *report) {
+	defer wg.Done()
+
+	entry, err := checkBin(ctx, client, httpClient, j)
+	if err != nil {
+		entry = reportEntry{Cli: j.bin.InstallName(), Platform: j.platform.String(), Status: statusError, Error: err.Error()}
+	}
+	r.add(entry)
+}
+
+// checkBin resolves the available release and compares it to what the state file says is installed, nothing is downloaded
+func checkBin(ctx context.Context, client *github.Client, httpClient *http.Client, j job) (reportEntry, error) {
+	binConfig := j.bin
+	state := j.state
+	available, err := resolveAsset(ctx, client, httpClient, binConfig, j.platform)
+	if err != nil {
+		return reportEntry{}, err
+	}
+
+	entry := reportEntry{Cli: binConfig.InstallName(), Platform: j.platform.String(), Available: available.version(), Asset: available.AssetName}
+
+	installed, ok := state.get(binConfig.InstallName())
+
+	switch {
+	case !ok:
+		entry.Status = statusNotInstalled
+		return entry, nil
+	case available.AssetID != 0 && (installed.Tag != available.Tag || installed.AssetID != available.AssetID):
+		entry.Status = statusOutdated
+	case available.AssetID == 0 && installed.DownloadURL != available.DownloadURL:
+		entry.Status = statusOutdated
+	default:
+		entry.Status = statusUpToDate
+	}
+	entry.Installed = installed.version()
+
+	checksum, err := util.FileSHA256(filepath.Join(j.saveLocation, binConfig.InstallName()))
+	if err != nil || checksum != installed.BinarySHA256 {
+		entry.Status = statusModified
+	}
+	return entry, nil
+}
+
+// version returns the tag or the asset name for nonGithubURL bins that don't have any tag
+func (l lockEntry) version() string {
+	if l.Tag != "" {
+		return l.Tag
+	}
+	return l.AssetName
+}
+
+// print writes the report as a table or as json
+func (r *report) print(w io.Writer, output string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.Slice(r.Bins, func(i, j int) bool {
+		if r.Bins[i].Cli != r.Bins[j].Cli {
+			return strings.Compare(r.Bins[i].Cli, r.Bins[j].Cli) < 0
+		}
+		return strings.Compare(r.Bins[i].Platform, r.Bins[j].Platform) < 0
+	})
+
+	switch output {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case outputTable, "":
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "CLI\tPLATFORM\tINSTALLED\tAVAILABLE\tSTATUS")
+		for _, entry := range r.Bins {
+			status := entry.Status
+			if entry.Error != "" {
+				status += ": " + entry.Error
+			}
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", entry.Cli, entry.Platform, entry.Installed, entry.Available, status)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format %v, supported formats are %v and %v", output, outputTable, outputJSON)
+}
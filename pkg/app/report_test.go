@@ -0,0 +1,149 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/NissesSenap/gitHubBinDl/pkg/util"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBin(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/tektoncd/cli/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v1.1.0", "assets": [{"id": 2, "name": "tkn_Linux_x86_64.tar.gz"}]}`)
+	})
+	client, server := newTestGitHubClient(t, mux)
+	defer server.Close()
+
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+
+	workspace := getEnv("TEMP_DIR", "/tmp")
+	folder, err := ioutil.TempDir(workspace, "testReport")
+	if err != nil {
+		t.Fatalf("Unable to create a tmp dir %v", err)
+	}
+	defer viper.Reset()
+	viper.Set(config.DefaultSaveLocationKey, folder)
+
+	for _, cli := range []string{"old", "current", "changed"} {
+		if err := ioutil.WriteFile(filepath.Join(folder, cli), []byte(cli), 0755); err != nil {
+			t.Fatalf("Unable to create file %v", err)
+		}
+	}
+	checksum, err := util.FileSHA256(filepath.Join(folder, "current"))
+	if err != nil {
+		t.Fatalf("Unable to hash file %v", err)
+	}
+	oldChecksum, _ := util.FileSHA256(filepath.Join(folder, "old"))
+
+	state := &stateFile{entries: map[string]stateEntry{
+		"old":     {lockEntry: lockEntry{Cli: "old", Tag: "v1.0.0", AssetID: 1}, BinarySHA256: oldChecksum},
+		"current": {lockEntry: lockEntry{Cli: "current", Tag: "v1.1.0", AssetID: 2}, BinarySHA256: checksum},
+		"changed": {lockEntry: lockEntry{Cli: "changed", Tag: "v1.1.0", AssetID: 2}, BinarySHA256: checksum},
+	}}
+
+	tests := []struct {
+		cli       string
+		expectOut string
+	}{
+		{cli: "old", expectOut: statusOutdated},
+		{cli: "current", expectOut: statusUpToDate},
+		{cli: "changed", expectOut: statusModified},
+		{cli: "new", expectOut: statusNotInstalled},
+	}
+
+	r := &report{}
+	for _, tests := range tests {
+		entry, err := checkBin(ctx, client, nil, job{bin: config.Bin{Cli: tests.cli, Owner: "tektoncd", Repo: "cli", Match: "linux_x86_64"}, platform: hostPlatform(), saveLocation: folder, state: state})
+		if err != nil {
+			t.Errorf("Unable to check %v, err: %v", tests.cli, err)
+			continue
+		}
+		assert.Equal(t, tests.expectOut, entry.Status, tests.cli)
+		assert.Equal(t, "v1.1.0", entry.Available, tests.cli)
+		r.add(entry)
+	}
+
+	var table bytes.Buffer
+	assert.NoError(t, r.print(&table, outputTable))
+	assert.Contains(t, table.String(), "CLI")
+	assert.Contains(t, table.String(), "v1.0.0")
+
+	var out bytes.Buffer
+	assert.NoError(t, r.print(&out, outputJSON))
+	var parsed report
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &parsed))
+	assert.Len(t, parsed.Bins, 4)
+	assert.Equal(t, "changed", parsed.Bins[0].Cli)
+
+	assert.Error(t, r.print(&out, "xml"))
+}
+
+func TestReportResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []string
+		expectErr error
+	}{
+		{name: "up to date", statuses: []string{statusUpToDate, statusUpToDate}},
+		{name: "outdated", statuses: []string{statusUpToDate, statusOutdated}, expectErr: ErrUpdatesAvailable},
+		{name: "not installed", statuses: []string{statusNotInstalled}, expectErr: ErrUpdatesAvailable},
+		{name: "modified", statuses: []string{statusModified}, expectErr: ErrUpdatesAvailable},
+		{name: "error", statuses: []string{statusUpToDate, statusError}, expectErr: ErrCheckFailed},
+		{name: "error and outdated", statuses: []string{statusOutdated, statusError}, expectErr: ErrCheckFailed},
+		{name: "only errors", statuses: []string{statusError, statusError}, expectErr: ErrCheckFailed},
+	}
+
+	for _, tests := range tests {
+		r := &report{}
+		for i, status := range tests.statuses {
+			r.add(reportEntry{Cli: fmt.Sprintf("cli%d", i), Status: status})
+		}
+		assert.Equal(t, tests.expectErr, r.result(), tests.name)
+	}
+}
+
+// a bin that can't be resolved is reported with the error instead of stopping the report
+func TestReportBinError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/tektoncd/cli/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v1.1.0", "assets": [{"id": 2, "name": "tkn_Linux_x86_64.tar.gz"}]}`)
+	})
+	client, server := newTestGitHubClient(t, mux)
+	defer server.Close()
+
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	state := &stateFile{entries: map[string]stateEntry{}}
+
+	r := &report{}
+	var wg sync.WaitGroup
+	for _, bin := range []config.Bin{
+		{Cli: "tkn", Owner: "tektoncd", Repo: "cli", Match: "linux_x86_64"},
+		{Cli: "missing", Owner: "tektoncd", Repo: "missing"},
+	} {
+		wg.Add(1)
+		reportBin(ctx, &wg, client, nil, job{bin: bin, platform: hostPlatform(), saveLocation: "/nonexistent", state: state}, r)
+	}
+	wg.Wait()
+
+	assert.Len(t, r.Bins, 2)
+	statuses := map[string]reportEntry{}
+	for _, entry := range r.Bins {
+		statuses[entry.Cli] = entry
+	}
+	assert.Equal(t, statusNotInstalled, statuses["tkn"].Status)
+	assert.Equal(t, statusError, statuses["missing"].Status)
+	assert.NotEmpty(t, statuses["missing"].Error)
+}
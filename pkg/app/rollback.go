@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/NissesSenap/gitHubBinDl/pkg/util"
+	"github.com/go-logr/logr"
+	"github.com/spf13/viper"
+)
+
+// Rollback restores a backup of the cli, the newest backup is used if to is empty.
+// to can be the version in the backup name or a date, the newest backup from that date is then used.
+// The backup is kept so it's possible to rollback again.
+// Bins downloaded for several platforms is rolled back in the folder of the platform flag, the host platform by default.
+func Rollback(ctx context.Context, configItem *config.Items, cliName, to string) error {
+	log := logr.FromContext(ctx)
+
+	targetPlatform, err := parsePlatform(viper.GetString(config.DefaultPlatformKey))
+	if err != nil {
+		return err
+	}
+	jobs, err := createJobs(configItem, targetPlatform)
+	if err != nil {
+		return err
+	}
+
+	// a cli that is not in the config is rolled back in the saveLocation
+	rollbackJob := job{saveLocation: binLocation(), backupLocation: backupLocation(), platform: targetPlatform}
+	for _, j := range jobs {
+		if j.bin.InstallName() == cliName && j.platform == targetPlatform {
+			rollbackJob = j
+			break
+		}
+	}
+	saveLocation := rollbackJob.saveLocation
+	backupLocation := rollbackJob.backupLocation
+
+	backups, err := listBackups(cliName, backupLocation)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("%v: no backups found in %v", cliName, backupLocation)
+	}
+
+	chosen := backups[0]
+	if to != "" {
+		found := false
+		for _, b := range backups {
+			// backups are sorted with the newest first
+			if b.suffix == to || b.suffix == backupVersion(to) || b.modTime.Local().Format(util.DateFormat) == to {
+				chosen = b
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%v: no backup of version or date %v found in %v", cliName, to, backupLocation)
+		}
+	}
+
+	fmt.Printf("Available backups for %v:\n", cliName)
+	for _, b := range backups {
+		marker := " "
+		if b.location == chosen.location {
+			marker = "*"
+		}
+		fmt.Printf("%v %v\t%v\t%v\n", marker, b.suffix, b.modTime.Local().Format(util.DateFormat), b.location)
+	}
+
+	target := filepath.Join(saveLocation, cliName)
+	if err := restoreBackup(chosen.location, target); err != nil {
+		return err
+	}
+	log.Info("Rolled back", "cli", cliName, "backup", chosen.name)
+
+	// the installed file is no longer what the state file says, the next install will download again
+	state, err := readStateFile(saveLocation)
+	if err != nil {
+		return err
+	}
+	state.remove(cliName)
+	if err := state.write(saveLocation); err != nil {
+		return err
+	}
+
+	// completion is only set on jobs for the host platform
+	if rollbackJob.bin.CompletionLocation != "" {
+		return saveCompletion(ctx, saveLocation, cliName, rollbackJob.bin.CompletionLocation, rollbackJob.bin.CompletionArgs)
+	}
+	return nil
+}
+
+// restoreBackup copies the backup next to the target and renames it, the target is never half written
+func restoreBackup(backupLocation, target string) error {
+	srcStat, err := os.Stat(backupLocation)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".rollback")
+	if err := copyFileContents(backupLocation, tmp, srcStat.Mode()); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
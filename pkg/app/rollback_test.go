@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollback(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+
+	workspace := getEnv("TEMP_DIR", "/tmp")
+	folder, err := ioutil.TempDir(workspace, "testRollback")
+	if err != nil {
+		t.Fatalf("Unable to create a tmp dir %v", err)
+	}
+	defer viper.Reset()
+	viper.Set(config.DefaultSaveLocationKey, folder)
+
+	files := []struct {
+		name    string
+		content string
+		modTime time.Time
+	}{
+		{name: "tkn", content: "broken", modTime: time.Now()},
+		{name: "tkn_2021-01-10", content: "old", modTime: time.Date(2021, 1, 10, 12, 0, 0, 0, time.Local)},
+		{name: "tkn_v0.15.0", content: "newer", modTime: time.Date(2021, 2, 10, 12, 0, 0, 0, time.Local)},
+		{name: "tkn_v0.14.0", content: "older", modTime: time.Date(2021, 2, 1, 12, 0, 0, 0, time.Local)},
+	}
+	for _, file := range files {
+		location := filepath.Join(folder, file.name)
+		if err := ioutil.WriteFile(location, []byte(file.content), 0755); err != nil {
+			t.Fatalf("Unable to create file %v", err)
+		}
+		if err := os.Chtimes(location, file.modTime, file.modTime); err != nil {
+			t.Fatalf("Unable to change time of file %v", err)
+		}
+	}
+	state := &stateFile{entries: map[string]stateEntry{"tkn": {lockEntry: lockEntry{Cli: "tkn", Tag: "v1.0.0"}}}}
+	if err := state.write(folder); err != nil {
+		t.Fatalf("Unable to write state %v", err)
+	}
+
+	backups, err := listBackups("tkn", folder)
+	assert.NoError(t, err)
+	assert.Len(t, backups, 3)
+
+	configItem := &config.Items{Bins: []config.Bin{{Cli: "tkn"}}}
+
+	assert.NoError(t, Rollback(ctx, configItem, "tkn", ""))
+	content, _ := ioutil.ReadFile(filepath.Join(folder, "tkn"))
+	assert.Equal(t, "newer", string(content))
+
+	assert.NoError(t, Rollback(ctx, configItem, "tkn", "v0.14.0"))
+	content, _ = ioutil.ReadFile(filepath.Join(folder, "tkn"))
+	assert.Equal(t, "older", string(content))
+
+	assert.NoError(t, Rollback(ctx, configItem, "tkn", "2021-01-10"))
+	content, _ = ioutil.ReadFile(filepath.Join(folder, "tkn"))
+	assert.Equal(t, "old", string(content))
+
+	// the backup is kept
+	content, _ = ioutil.ReadFile(filepath.Join(folder, "tkn_2021-01-10"))
+	assert.Equal(t, "old", string(content))
+
+	state, err = readStateFile(folder)
+	assert.NoError(t, err)
+	assert.NotContains(t, state.entries, "tkn")
+
+	assert.Error(t, Rollback(ctx, configItem, "tkn", "2020-01-01"))
+	assert.Error(t, Rollback(ctx, configItem, "helm", ""))
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/util"
+	"gopkg.in/yaml.v2"
+)
+
+// stateFileName is saved in saveLocation and keeps track on what is installed there
+const stateFileName = ".githubbindl-state.yaml"
+
+// stateEntry what got installed for a cli, BinarySHA256 is the hash of the installed file and not the downloaded asset
+type stateEntry struct {
+	lockEntry    `yaml:",inline"`
+	BinarySHA256 string    `yaml:"binarySha256"`
+	InstalledAt  time.Time `yaml:"installedAt"`
+}
+
+// stateFile the content of the state file, the mutex is needed since all bins are installed at the same time
+type stateFile struct {
+	Bins []stateEntry `yaml:"bins"`
+
+	mu      sync.Mutex
+	entries map[string]stateEntry
+}
+
+// readStateFile reads the state file in saveLocation, if it don't exist a empty state is returned
+func readStateFile(saveLocation string) (*stateFile, error) {
+	state := &stateFile{entries: make(map[string]stateEntry)}
+	location := filepath.Join(saveLocation, stateFileName)
+
+	source, err := ioutil.ReadFile(location) // #nosec G304
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("unable to read state file: %v", err)
+	}
+
+	if err := yaml.Unmarshal(source, state); err != nil {
+		return nil, fmt.Errorf("unable to parse state file %v: %v", location, err)
+	}
+	for _, entry := range state.Bins {
+		state.entries[entry.Cli] = entry
+	}
+	return state, nil
+}
+
+// unchanged returns the installed entry if the resolved entry is what is installed and the file on disk haven't been changed.
+// GitHub assets is compared on tag and asset ID, a nonGithubURL have to be downloaded first since only the sha256 can tell if it's changed.
+// A nonGithubURL with versionFrom is compared on the version and url instead.
+func (s *stateFile) unchanged(entry lockEntry, binaryLocation string) (stateEntry, bool) {
+	installed, ok := s.get(entry.Cli)
+	if !ok {
+		return stateEntry{}, false
+	}
+
+	if entry.AssetID != 0 {
+		if entry.Tag != installed.Tag || entry.AssetID != installed.AssetID {
+			return stateEntry{}, false
+		}
+	} else if entry.DownloadURL != installed.DownloadURL || entry.Tag != installed.Tag || (entry.SHA256 == "" && entry.Tag == "") {
+		return stateEntry{}, false
+	}
+	if entry.SHA256 != "" && entry.SHA256 != installed.SHA256 {
+		return stateEntry{}, false
+	}
+
+	checksum, err := util.FileSHA256(binaryLocation)
+	if err != nil || checksum != installed.BinarySHA256 {
+		return stateEntry{}, false
+	}
+	return installed, true
+}
+
+// get returns the installed entry for a cli
+func (s *stateFile) get(cliName string) (stateEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[cliName]
+	return entry, ok
+}
+
+// remove forgets a cli, used when the installed file no longer is what got downloaded
+func (s *stateFile) remove(cliName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, cliName)
+}
+
+// set adds or replaces the installed entry for a cli
+func (s *stateFile) set(entry stateEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[entry.Cli] = entry
+}
+
+// write saves the state file in saveLocation, clis that isn't in the config is kept since they are still installed
+func (s *stateFile) write(saveLocation string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Bins = nil
+	for _, entry := range s.entries {
+		s.Bins = append(s.Bins, entry)
+	}
+	sort.Slice(s.Bins, func(i, j int) bool {
+		return strings.Compare(s.Bins[i].Cli, s.Bins[j].Cli) < 0
+	})
+
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(saveLocation, stateFileName), out, 0644) // #nosec G306
+}
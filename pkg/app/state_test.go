@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// a second run with the same release don't download anything unless the installed file is changed
+func TestStateSkipsUnchanged(t *testing.T) {
+	downloads := 0
+	tag := "v1.0.0"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/tektoncd/cli/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": "%v", "assets": [{"id": 1, "name": "tkn"}]}`, tag)
+	})
+	mux.HandleFunc("/repos/tektoncd/cli/releases/assets/1", func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		fmt.Fprintf(w, "tkn %v", tag)
+	})
+	client, server := newTestGitHubClient(t, mux)
+	defer server.Close()
+
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+
+	workspace := getEnv("TEMP_DIR", "/tmp")
+	folder, err := ioutil.TempDir(workspace, "testState")
+	if err != nil {
+		t.Fatalf("Unable to create a tmp dir %v", err)
+	}
+	defer viper.Reset()
+	viper.Set(config.DefaultSaveLocationKey, folder)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024*1024)
+
+	bin := config.Bin{Cli: "tkn", Owner: "tektoncd", Repo: "cli", Match: "tkn"}
+	lock := &lockFile{entries: make(map[string]lockEntry)}
+
+	install := func() {
+		state, err := readStateFile(folder)
+		if err != nil {
+			t.Fatalf("Unable to read state file %v", err)
+		}
+		if err := installBin(ctx, client, server.Client(), job{bin: bin, platform: hostPlatform(), saveLocation: folder, backupLocation: folder, state: state}, lock); err != nil {
+			t.Fatalf("Unable to install bin %v", err)
+		}
+		if err := state.write(folder); err != nil {
+			t.Fatalf("Unable to write state file %v", err)
+		}
+	}
+
+	install()
+	install()
+	assert.Equal(t, 1, downloads, "the same release should only be downloaded once")
+
+	// someone changed the installed file
+	if err := ioutil.WriteFile(filepath.Join(folder, "tkn"), []byte("changed"), 0755); err != nil {
+		t.Fatalf("Unable to change installed file %v", err)
+	}
+	install()
+	assert.Equal(t, 2, downloads)
+
+	tag = "v1.1.0"
+	install()
+	assert.Equal(t, 3, downloads)
+
+	state, err := readStateFile(folder)
+	if err != nil {
+		t.Fatalf("Unable to read state file %v", err)
+	}
+	assert.Equal(t, "v1.1.0", state.entries["tkn"].Tag)
+	assert.Equal(t, int64(1), state.entries["tkn"].AssetID)
+	assert.False(t, state.entries["tkn"].InstalledAt.IsZero())
+}
@@ -0,0 +1,345 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxLogEntries how many of the entries a search in the transparency log returns that is fetched
+const maxLogEntries = 10
+
+// trustedRoot the certificate authorities and transparency logs from a sigstore trusted_root.json
+type trustedRoot struct {
+	certs []*x509.Certificate
+	tlogs []transparencyLog
+}
+
+// transparencyLog a Rekor log that signs the entries, end is zero while the key is in use
+type transparencyLog struct {
+	baseURL    string
+	logID      []byte
+	publicKey  crypto.PublicKey
+	start, end time.Time
+}
+
+// tlogEntry a transparency log entry from a bundle.
+// The signed entry timestamp is the promise from the log that body was added at integratedTime.
+type tlogEntry struct {
+	logIndex             int64
+	logID                []byte
+	integratedTime       int64
+	body                 []byte
+	signedEntryTimestamp []byte
+}
+
+// rekorBody the kind of a canonicalized log entry, the spec depends on the kind
+type rekorBody struct {
+	Kind string          `json:"kind"`
+	Spec json.RawMessage `json:"spec"`
+}
+
+// rekorHash a digest in a log entry, the value is hex encoded
+type rekorHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// readTrustRoot reads a sigstore trusted_root.json.
+// The certificate authorities is what keyless certificates chain up to, and the transparency logs is what the log time is verified with.
+func readTrustRoot(location string) (trustedRoot, error) {
+	var root trustedRoot
+	data, err := ioutil.ReadFile(location) // #nosec G304
+	if err != nil {
+		return root, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return root, errors.New("expected a sigstore trusted_root.json")
+	}
+
+	var trusted struct {
+		CertificateAuthorities []struct {
+			CertChain struct {
+				Certificates []struct {
+					RawBytes []byte `json:"rawBytes"`
+				} `json:"certificates"`
+			} `json:"certChain"`
+		} `json:"certificateAuthorities"`
+		Tlogs []struct {
+			BaseURL   string `json:"baseUrl"`
+			PublicKey struct {
+				RawBytes []byte `json:"rawBytes"`
+				ValidFor struct {
+					Start time.Time `json:"start"`
+					End   time.Time `json:"end"`
+				} `json:"validFor"`
+			} `json:"publicKey"`
+			LogID struct {
+				KeyID []byte `json:"keyId"`
+			} `json:"logId"`
+		} `json:"tlogs"`
+	}
+	if err := json.Unmarshal(data, &trusted); err != nil {
+		return root, err
+	}
+	for _, authority := range trusted.CertificateAuthorities {
+		for _, raw := range authority.CertChain.Certificates {
+			cert, err := x509.ParseCertificate(raw.RawBytes)
+			if err != nil {
+				return root, err
+			}
+			root.certs = append(root.certs, cert)
+		}
+	}
+	for _, tlog := range trusted.Tlogs {
+		publicKey, err := x509.ParsePKIXPublicKey(tlog.PublicKey.RawBytes)
+		if err != nil {
+			return root, fmt.Errorf("invalid transparency log key: %v", err)
+		}
+		root.tlogs = append(root.tlogs, transparencyLog{
+			baseURL:   strings.TrimSuffix(tlog.BaseURL, "/"),
+			logID:     tlog.LogID.KeyID,
+			publicKey: publicKey,
+			start:     tlog.PublicKey.ValidFor.Start,
+			end:       tlog.PublicKey.ValidFor.End,
+		})
+	}
+	if len(root.certs) == 0 {
+		return root, errors.New("no certificate authorities found")
+	}
+	if len(root.tlogs) == 0 {
+		return root, errors.New("no transparency logs found")
+	}
+	return root, nil
+}
+
+// logTime returns the time from the first log entry that is signed by a trusted log and is about the signature, digest and certificate.
+// digest is the sha256 of what is signed. Without a verified entry the time the certificate was used can't be trusted.
+func (r trustedRoot) logTime(entries []tlogEntry, digest, signature []byte, leaf *x509.Certificate) (time.Time, error) {
+	if len(entries) == 0 {
+		return time.Time{}, errors.New("no transparency log entry found")
+	}
+	var lastErr error
+	for _, entry := range entries {
+		if err := r.verifyTlogEntry(entry); err != nil {
+			lastErr = err
+			continue
+		}
+		if err := entry.verifyBody(digest, signature, leaf); err != nil {
+			lastErr = err
+			continue
+		}
+		return time.Unix(entry.integratedTime, 0), nil
+	}
+	return time.Time{}, lastErr
+}
+
+// searchEntries looks up the entries about the sha256 digest in the transparency logs of the trust root.
+// Nothing the log returns is trusted, the entries is verified with logTime like the entries from a bundle.
+func (r trustedRoot) searchEntries(ctx context.Context, httpClient *http.Client, cliName string, digest []byte) ([]tlogEntry, error) {
+	query, err := json.Marshal(map[string]string{"hash": "sha256:" + hex.EncodeToString(digest)})
+	if err != nil {
+		return nil, err
+	}
+	var entries []tlogEntry
+	searched := map[string]bool{}
+	for _, tlog := range r.tlogs {
+		if tlog.baseURL == "" || searched[tlog.baseURL] {
+			continue
+		}
+		searched[tlog.baseURL] = true
+
+		var uuids []string
+		if err := rekorRequest(ctx, httpClient, cliName, http.MethodPost, tlog.baseURL+"/api/v1/index/retrieve", query, &uuids); err != nil {
+			return nil, err
+		}
+		if len(uuids) > maxLogEntries {
+			uuids = uuids[:maxLogEntries]
+		}
+		for _, uuid := range uuids {
+			var logEntries map[string]struct {
+				Body           []byte `json:"body"`
+				IntegratedTime int64  `json:"integratedTime"`
+				LogID          string `json:"logID"`
+				LogIndex       int64  `json:"logIndex"`
+				Verification   struct {
+					SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+				} `json:"verification"`
+			}
+			if err := rekorRequest(ctx, httpClient, cliName, http.MethodGet, tlog.baseURL+"/api/v1/log/entries/"+uuid, nil, &logEntries); err != nil {
+				return nil, err
+			}
+			for _, logEntry := range logEntries {
+				logID, err := hex.DecodeString(logEntry.LogID)
+				if err != nil {
+					return nil, fmt.Errorf("invalid log ID %v: %v", logEntry.LogID, err)
+				}
+				entries = append(entries, tlogEntry{
+					logIndex:             logEntry.LogIndex,
+					logID:                logID,
+					integratedTime:       logEntry.IntegratedTime,
+					body:                 logEntry.Body,
+					signedEntryTimestamp: logEntry.Verification.SignedEntryTimestamp,
+				})
+			}
+		}
+	}
+	return entries, nil
+}
+
+// rekorRequest sends a request to the Rekor API and decodes the JSON response in to out
+func rekorRequest(ctx context.Context, httpClient *http.Client, cliName, method, url string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, cancel, err := sendRequest(ctx, httpClient, cliName, req)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	defer resp.Body.Close()
+
+	data, err := readLimited(resp.Body, cliName)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%v: invalid response from %v: %v", cliName, url, err)
+	}
+	return nil
+}
+
+// verifyTlogEntry checks the signed entry timestamp with the key of the log in the trust root
+func (r trustedRoot) verifyTlogEntry(entry tlogEntry) error {
+	if len(entry.signedEntryTimestamp) == 0 {
+		return errors.New("the transparency log entry don't have a signed entry timestamp")
+	}
+	for _, tlog := range r.tlogs {
+		if !bytes.Equal(tlog.logID, entry.logID) {
+			continue
+		}
+		integratedTime := time.Unix(entry.integratedTime, 0)
+		if integratedTime.Before(tlog.start) || (!tlog.end.IsZero() && integratedTime.After(tlog.end)) {
+			return fmt.Errorf("the transparency log %x isn't valid at %v", entry.logID, integratedTime)
+		}
+		// the canonical JSON of the entry, the keys is sorted
+		payload, err := json.Marshal(struct {
+			Body           string `json:"body"`
+			IntegratedTime int64  `json:"integratedTime"`
+			LogID          string `json:"logID"`
+			LogIndex       int64  `json:"logIndex"`
+		}{
+			Body:           base64.StdEncoding.EncodeToString(entry.body),
+			IntegratedTime: entry.integratedTime,
+			LogID:          hex.EncodeToString(entry.logID),
+			LogIndex:       entry.logIndex,
+		})
+		if err != nil {
+			return err
+		}
+		if err := verifySignature(tlog.publicKey, payload, entry.signedEntryTimestamp); err != nil {
+			return fmt.Errorf("invalid signed entry timestamp: %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("the transparency log %x isn't in the trust root", entry.logID)
+}
+
+// verifyBody checks that the log entry is about the signature, digest and certificate.
+// hashedrekord is used for signed blobs, dsse and intoto for attestations.
+func (e tlogEntry) verifyBody(digest, signature []byte, leaf *x509.Certificate) error {
+	var body rekorBody
+	if err := json.Unmarshal(e.body, &body); err != nil {
+		return fmt.Errorf("invalid transparency log entry: %v", err)
+	}
+
+	var hash rekorHash
+	var signatures, certificates [][]byte
+	switch body.Kind {
+	case "hashedrekord":
+		var spec struct {
+			Data struct {
+				Hash rekorHash `json:"hash"`
+			} `json:"data"`
+			Signature struct {
+				Content   []byte `json:"content"`
+				PublicKey struct {
+					Content []byte `json:"content"`
+				} `json:"publicKey"`
+			} `json:"signature"`
+		}
+		if err := json.Unmarshal(body.Spec, &spec); err != nil {
+			return fmt.Errorf("invalid hashedrekord entry: %v", err)
+		}
+		hash = spec.Data.Hash
+		signatures = [][]byte{spec.Signature.Content}
+		certificates = [][]byte{spec.Signature.PublicKey.Content}
+	case "dsse":
+		var spec struct {
+			PayloadHash rekorHash `json:"payloadHash"`
+			Signatures  []struct {
+				Signature []byte `json:"signature"`
+				Verifier  []byte `json:"verifier"`
+			} `json:"signatures"`
+		}
+		if err := json.Unmarshal(body.Spec, &spec); err != nil {
+			return fmt.Errorf("invalid dsse entry: %v", err)
+		}
+		hash = spec.PayloadHash
+		for _, s := range spec.Signatures {
+			signatures = append(signatures, s.Signature)
+			certificates = append(certificates, s.Verifier)
+		}
+	case "intoto":
+		// the signatures is encoded differently between the versions, the certificate and payload binds the entry well enough
+		var spec struct {
+			Content struct {
+				Envelope struct {
+					Signatures []struct {
+						PublicKey []byte `json:"publicKey"`
+					} `json:"signatures"`
+				} `json:"envelope"`
+				PayloadHash rekorHash `json:"payloadHash"`
+			} `json:"content"`
+		}
+		if err := json.Unmarshal(body.Spec, &spec); err != nil {
+			return fmt.Errorf("invalid intoto entry: %v", err)
+		}
+		hash = spec.Content.PayloadHash
+		for _, s := range spec.Content.Envelope.Signatures {
+			certificates = append(certificates, s.PublicKey)
+		}
+	default:
+		return fmt.Errorf("unsupported transparency log entry %q", body.Kind)
+	}
+
+	if hash.Algorithm != "sha256" || !strings.EqualFold(hash.Value, hex.EncodeToString(digest)) {
+		return fmt.Errorf("the transparency log entry is about %v:%v and not the signed content", hash.Algorithm, hash.Value)
+	}
+	for i, certificate := range certificates {
+		certs, err := parseCertificates(certificate)
+		if err != nil || !bytes.Equal(certs[0].Raw, leaf.Raw) {
+			continue
+		}
+		if signatures != nil && !bytes.Equal(signatures[i], signature) {
+			continue
+		}
+		return nil
+	}
+	return errors.New("the transparency log entry is about another signature or certificate")
+}
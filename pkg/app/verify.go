@@ -0,0 +1,189 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v33/github"
+)
+
+// sidecar a file published next to the asset, like a signature or a certificate
+type sidecar struct {
+	name string
+	data []byte
+}
+
+// findReleaseFile returns the release asset matching pattern, or the asset name with one of the suffixes if pattern is empty.
+// nil is returned if no suffix is found, a pattern that don't match anything is an error.
+func findReleaseFile(cliName string, release *github.RepositoryRelease, assetName, pattern string, suffixes []string) (*releaseFile, error) {
+	toFile := func(a *github.ReleaseAsset) *releaseFile {
+		return &releaseFile{name: a.GetName(), id: a.GetID(), url: a.GetBrowserDownloadURL()}
+	}
+
+	if pattern != "" {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%v: invalid pattern %q: %v", cliName, pattern, err)
+		}
+		for _, a := range release.Assets {
+			if a.GetName() != assetName && r.MatchString(a.GetName()) {
+				return toFile(a), nil
+			}
+		}
+		return nil, fmt.Errorf("%v: unable to find a asset matching %q in release %v", cliName, pattern, release.GetTagName())
+	}
+
+	for _, suffix := range suffixes {
+		for _, a := range release.Assets {
+			if strings.EqualFold(a.GetName(), assetName+suffix) {
+				return toFile(a), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// fetchSidecar downloads a file published next to the asset, see findReleaseFile.
+// A nonGithubURL don't have a release, pattern is then a url and without it the suffixes is added to the download url.
+// nil is returned if nothing is found.
+func fetchSidecar(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, entry lockEntry, pattern string, suffixes []string) (*sidecar, error) {
+	if entry.release == nil {
+		if pattern != "" {
+			data, _, err := fetchURL(ctx, httpClient, binConfig.Cli, pattern)
+			if err != nil {
+				return nil, err
+			}
+			return &sidecar{name: pattern, data: data}, nil
+		}
+		// the server don't list what it have, the first suffix that can be downloaded is used
+		for _, suffix := range suffixes {
+			if data, _, err := fetchURL(ctx, httpClient, binConfig.Cli, entry.DownloadURL+suffix); err == nil {
+				return &sidecar{name: entry.AssetName + suffix, data: data}, nil
+			}
+		}
+		return nil, nil
+	}
+
+	file, err := findReleaseFile(binConfig.Cli, entry.release, entry.AssetName, pattern, suffixes)
+	if err != nil || file == nil {
+		return nil, err
+	}
+	data, _, err := fetchAsset(ctx, client, httpClient, binConfig, lockEntry{AssetID: file.id, DownloadURL: file.url})
+	if err != nil {
+		return nil, fmt.Errorf("%v: unable to download %v: %v", binConfig.Cli, file.name, err)
+	}
+	return &sidecar{name: file.name, data: data}, nil
+}
+
+// signsAsset returns true if the bin have a signature that covers the asset, it's then enough for requireChecksum
+func signsAsset(binConfig config.Bin) bool {
+	return binConfig.Verify != nil && (binConfig.Verify.Cosign != nil || binConfig.Verify.Provenance != nil || signsChecksum(binConfig))
+}
+
+// signsChecksum returns true if the checksum file is verified together with the signature, it don't have to be checked again
+func signsChecksum(binConfig config.Bin) bool {
+	return binConfig.Verify != nil && (binConfig.Verify.GPG != nil || binConfig.Verify.Minisign != nil)
+}
+
+// detachedVerifier checks a detached signature of signed
+type detachedVerifier func(signed blob, signature []byte) error
+
+// verifyDetached verifies a gpg or minisign signature.
+// The signature of the checksum file is used if there is one, the checksum file is then used to verify the asset.
+// Without a checksum file, or a signature of it, the signature have to be for the asset itself.
+func verifyDetached(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, entry lockEntry, data blob, method, pattern string, suffixes []string, verify detachedVerifier) error {
+	log := logr.FromContext(ctx)
+
+	var checksums []byte
+	var signature *sidecar
+	if entry.checksumFile != nil {
+		var err error
+		checksums, _, err = fetchAsset(ctx, client, httpClient, binConfig, lockEntry{AssetID: entry.checksumFile.id, DownloadURL: entry.checksumFile.url})
+		if err != nil {
+			return fmt.Errorf("%v: unable to download checksum file %v: %v", binConfig.Cli, entry.checksumFile.name, err)
+		}
+		checksumEntry := lockEntry{AssetName: entry.checksumFile.name, DownloadURL: entry.checksumFile.url, release: entry.release}
+		signature, err = fetchSidecar(ctx, client, httpClient, binConfig, checksumEntry, pattern, suffixes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if signature == nil {
+		var err error
+		signature, err = fetchSidecar(ctx, client, httpClient, binConfig, entry, pattern, suffixes)
+		if err != nil {
+			return err
+		}
+		if signature == nil {
+			return fmt.Errorf("%v: unable to find a %v signature for %v", binConfig.Cli, method, entry.AssetName)
+		}
+		if err := verify(data, signature.data); err != nil {
+			return fmt.Errorf("%v: %v signature %v is not valid for %v: %v", binConfig.Cli, method, signature.name, entry.AssetName, err)
+		}
+		log.Info("Verified signature", "cli", binConfig.Cli, "method", method, "asset", entry.AssetName, "signature", signature.name)
+		return nil
+	}
+
+	if err := verify(bytesBlob(checksums), signature.data); err != nil {
+		return fmt.Errorf("%v: %v signature %v is not valid for %v: %v", binConfig.Cli, method, signature.name, entry.checksumFile.name, err)
+	}
+	// the checksum file can be trusted now
+	digest, found, err := parseChecksum(checksums, entry.AssetName)
+	if err != nil {
+		return fmt.Errorf("%v: unable to parse checksum file %v: %v", binConfig.Cli, entry.checksumFile.name, err)
+	}
+	if !found {
+		return fmt.Errorf("%v: %v is missing in the signed checksum file %v", binConfig.Cli, entry.AssetName, entry.checksumFile.name)
+	}
+	matches, err := digestMatches(digest, data)
+	if err != nil {
+		return fmt.Errorf("%v: %v in %v", binConfig.Cli, err, entry.checksumFile.name)
+	}
+	if !matches {
+		return fmt.Errorf("%v: checksum mismatch for %v, the signed %v says %v", binConfig.Cli, entry.AssetName, entry.checksumFile.name, digest)
+	}
+	log.Info("Verified signature", "cli", binConfig.Cli, "method", method, "asset", entry.AssetName, "checksumFile", entry.checksumFile.name, "signature", signature.name)
+	return nil
+}
+
+// verifyDownload runs the signature and provenance verifications configured under verify, any failure stops the install
+func verifyDownload(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin, entry lockEntry, data blob) error {
+	if binConfig.Verify == nil {
+		return nil
+	}
+	if binConfig.Verify.Cosign != nil {
+		if err := verifyCosign(ctx, client, httpClient, binConfig, entry, data); err != nil {
+			return err
+		}
+	}
+	if gpg := binConfig.Verify.GPG; gpg != nil {
+		verify, err := gpgVerifier(gpg)
+		if err != nil {
+			return fmt.Errorf("%v: %v", binConfig.Cli, err)
+		}
+		if err := verifyDetached(ctx, client, httpClient, binConfig, entry, data, "gpg", gpg.Signature, gpgSignatureSuffixes, verify); err != nil {
+			return err
+		}
+	}
+	if minisign := binConfig.Verify.Minisign; minisign != nil {
+		verify, err := minisignVerifier(minisign)
+		if err != nil {
+			return fmt.Errorf("%v: %v", binConfig.Cli, err)
+		}
+		if err := verifyDetached(ctx, client, httpClient, binConfig, entry, data, "minisign", minisign.Signature, minisignSignatureSuffixes, verify); err != nil {
+			return err
+		}
+	}
+	if binConfig.Verify.Provenance != nil {
+		if err := verifyProvenance(ctx, client, httpClient, binConfig, entry, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
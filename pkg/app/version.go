@@ -0,0 +1,317 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const semverCore = `[vV]?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`
+
+// semverRegex finds a semantic version at the end of a tag, the optional v and any project prefix like release- or cli/ is ignored
+var semverRegex = regexp.MustCompile(`(?:^|[^0-9A-Za-z])` + semverCore)
+
+// exactSemverRegex is used when the tag prefix is known, what is left after the prefix have to be a version
+var exactSemverRegex = regexp.MustCompile(`^` + semverCore)
+
+// semver a parsed semantic version, original contains the tag it was parsed from
+type semver struct {
+	major      int
+	minor      int
+	patch      int
+	prerelease []string
+	original   string
+}
+
+// parseVersion parses a release tag as a semantic version.
+// If tagPrefix is set the tag have to start with it, else the tag is ignored.
+func parseVersion(tag, tagPrefix string) (semver, bool) {
+	match := semverRegex.FindStringSubmatch(tag)
+	if tagPrefix != "" {
+		if !strings.HasPrefix(tag, tagPrefix) {
+			return semver{}, false
+		}
+		match = exactSemverRegex.FindStringSubmatch(strings.TrimPrefix(tag, tagPrefix))
+	}
+	if match == nil {
+		return semver{}, false
+	}
+
+	v := semver{original: tag}
+	var err error
+	if v.major, err = strconv.Atoi(match[1]); err != nil {
+		return semver{}, false
+	}
+	if match[2] != "" {
+		if v.minor, err = strconv.Atoi(match[2]); err != nil {
+			return semver{}, false
+		}
+	}
+	if match[3] != "" {
+		if v.patch, err = strconv.Atoi(match[3]); err != nil {
+			return semver{}, false
+		}
+	}
+	if match[4] != "" {
+		v.prerelease = strings.Split(match[4], ".")
+	}
+	return v, true
+}
+
+// isPrerelease returns true if the version contains a prerelease part like -rc.1
+func (v semver) isPrerelease() bool {
+	return len(v.prerelease) > 0
+}
+
+// String returns major.minor.patch[-prerelease]
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.isPrerelease() {
+		s += "-" + strings.Join(v.prerelease, ".")
+	}
+	return s
+}
+
+// compare returns -1 if v < o, 0 if they are equal and 1 if v > o, follows the semver 2.0 precedence rules
+func (v semver) compare(o semver) int {
+	for _, pair := range [][2]int{{v.major, o.major}, {v.minor, o.minor}, {v.patch, o.patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// a version without prerelease have higher precedence
+	switch {
+	case !v.isPrerelease() && !o.isPrerelease():
+		return 0
+	case !v.isPrerelease():
+		return 1
+	case !o.isPrerelease():
+		return -1
+	}
+
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		if c := comparePrereleaseIdentifier(v.prerelease[i], o.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.prerelease) < len(o.prerelease):
+		return -1
+	case len(v.prerelease) > len(o.prerelease):
+		return 1
+	}
+	return 0
+}
+
+// comparePrereleaseIdentifier numeric identifiers are compared as numbers and always have lower precedence than alphanumeric
+func comparePrereleaseIdentifier(a, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// comparator a single check in a constraint, for example >=1.4.0
+type comparator struct {
+	operator string
+	version  semver
+	// upper is only set for != with a partial version like !=1.4, everything from version up to upper is then excluded
+	upper *semver
+}
+
+func (c comparator) check(v semver) bool {
+	result := v.compare(c.version)
+	switch c.operator {
+	case "=":
+		return result == 0
+	case "!=":
+		if c.upper != nil {
+			return result < 0 || v.compare(*c.upper) >= 0
+		}
+		return result != 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	}
+	return false
+}
+
+// constraint a list of comparator groups, a version have to match all comparators in at least one of the groups
+type constraint struct {
+	groups [][]comparator
+	// allowPrerelease is true if the user wrote a prerelease version in the constraint
+	allowPrerelease bool
+}
+
+var constraintOperatorRegex = regexp.MustCompile(`^(!=|>=|<=|=|>|<|~|\^)?\s*[vV]?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-([0-9A-Za-z.-]+))?$`)
+
+// parseConstraint parses a version constraint like ">=1.4.0, <2.0.0", "~1.4" or "^1.2.3 || ^2.0.0"
+// Comparators in a group are separated with comma or space and groups are separated by ||
+func parseConstraint(s string) (constraint, error) {
+	var c constraint
+	for _, group := range strings.Split(s, "||") {
+		var comparators []comparator
+		// allow writing ">= 1.4.0" by gluing operators to the following version
+		fields := strings.Fields(strings.Replace(group, ",", " ", -1))
+		for i := 0; i < len(fields); i++ {
+			term := fields[i]
+			if strings.Trim(term, "!=<>~^") == "" && i+1 < len(fields) {
+				i++
+				term += fields[i]
+			}
+			parsed, prerelease, err := parseComparator(term)
+			if err != nil {
+				return constraint{}, fmt.Errorf("invalid version constraint %q: %v", s, err)
+			}
+			c.allowPrerelease = c.allowPrerelease || prerelease
+			comparators = append(comparators, parsed...)
+		}
+		if len(comparators) == 0 {
+			return constraint{}, fmt.Errorf("invalid version constraint %q: empty group", s)
+		}
+		c.groups = append(c.groups, comparators)
+	}
+	return c, nil
+}
+
+// parseComparator turns a single term into one or two comparators, partial versions like 1.4 or 1.4.x are turned in to ranges
+func parseComparator(term string) ([]comparator, bool, error) {
+	match := constraintOperatorRegex.FindStringSubmatch(term)
+	if match == nil {
+		return nil, false, fmt.Errorf("unable to parse %q", term)
+	}
+	operator := match[1]
+	if operator == "" {
+		operator = "="
+	}
+
+	// parts contains the numbers that was written, a wildcard or a missing number ends the list
+	var parts []int
+	for _, p := range match[2:5] {
+		if p == "" || strings.ContainsAny(p, "xX*") {
+			break
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, false, err
+		}
+		parts = append(parts, n)
+	}
+
+	lower := semver{}
+	if len(parts) > 0 {
+		lower.major = parts[0]
+	}
+	if len(parts) > 1 {
+		lower.minor = parts[1]
+	}
+	if len(parts) > 2 {
+		lower.patch = parts[2]
+	}
+	if match[5] != "" {
+		if len(parts) != 3 {
+			return nil, false, fmt.Errorf("prerelease %q requires a full version", term)
+		}
+		lower.prerelease = strings.Split(match[5], ".")
+	}
+	prerelease := lower.isPrerelease()
+
+	// "*" or "x" matches everything
+	if len(parts) == 0 {
+		return []comparator{{operator: ">=", version: semver{}}}, prerelease, nil
+	}
+
+	// a partial version like 1.4 is the range >=1.4.0 <1.5.0-0, the operators is applied to the whole range like npm does
+	if len(parts) < 3 && operator != "~" && operator != "^" {
+		upper := semver{major: lower.major + 1}
+		if len(parts) == 2 {
+			upper = semver{major: lower.major, minor: lower.minor + 1}
+		}
+		// 2.0.0-0 is the smallest version of 2.0.0, the bounds is below any prerelease of it
+		upper.prerelease = []string{"0"}
+		lowest := lower
+		lowest.prerelease = []string{"0"}
+		switch operator {
+		case ">":
+			return []comparator{{operator: ">=", version: upper}}, prerelease, nil
+		case ">=":
+			return []comparator{{operator: ">=", version: lower}}, prerelease, nil
+		case "<":
+			return []comparator{{operator: "<", version: lowest}}, prerelease, nil
+		case "<=":
+			return []comparator{{operator: "<", version: upper}}, prerelease, nil
+		case "!=":
+			return []comparator{{operator: "!=", version: lower, upper: &upper}}, prerelease, nil
+		}
+		return []comparator{{operator: ">=", version: lower}, {operator: "<", version: upper}}, prerelease, nil
+	}
+
+	// upper is the first version that is not allowed, the position to bump depends on the operator
+	upper := semver{major: lower.major, minor: lower.minor, patch: lower.patch}
+	switch operator {
+	case "~":
+		if len(parts) == 1 {
+			upper = semver{major: lower.major + 1}
+		} else {
+			upper = semver{major: lower.major, minor: lower.minor + 1}
+		}
+	case "^":
+		switch {
+		case lower.major > 0 || len(parts) == 1:
+			upper = semver{major: lower.major + 1}
+		case lower.minor > 0 || len(parts) == 2:
+			upper = semver{minor: lower.minor + 1}
+		default:
+			upper = semver{patch: lower.patch + 1}
+		}
+	default:
+		return []comparator{{operator: operator, version: lower}}, prerelease, nil
+	}
+	// lower the upper bound below any prerelease of it, 2.0.0-0 is the smallest version of 2.0.0
+	upper.prerelease = []string{"0"}
+	return []comparator{{operator: ">=", version: lower}, {operator: "<", version: upper}}, prerelease, nil
+}
+
+// check returns true if the version is allowed by the constraint
+// prerelease versions are only allowed if the constraint itself contains a prerelease
+func (c constraint) check(v semver) bool {
+	if v.isPrerelease() && !c.allowPrerelease {
+		return false
+	}
+	for _, group := range c.groups {
+		ok := true
+		for _, comp := range group {
+			if !comp.check(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
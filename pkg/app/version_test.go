@@ -0,0 +1,113 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		tag       string
+		tagPrefix string
+		ok        bool
+		expectOut string
+	}{
+		{tag: "v1.4.0", ok: true, expectOut: "1.4.0"},
+		{tag: "1.4", ok: true, expectOut: "1.4.0"},
+		{tag: "release-2.0.1-rc.1", ok: true, expectOut: "2.0.1-rc.1"},
+		{tag: "kustomize/v5.0.0", ok: true, expectOut: "5.0.0"},
+		{tag: "v1.2.3+build.5", ok: true, expectOut: "1.2.3"},
+		{tag: "nightly", ok: false},
+		{tag: "cli-v0.15.0", tagPrefix: "cli-", ok: true, expectOut: "0.15.0"},
+		{tag: "api/v0.13.0", tagPrefix: "kustomize/", ok: false},
+		{tag: "kustomize/extra-v5.0.0", tagPrefix: "kustomize/", ok: false},
+	}
+
+	for _, tests := range tests {
+		v, ok := parseVersion(tests.tag, tests.tagPrefix)
+		assert.Equal(t, tests.ok, ok, tests.tag)
+		if ok {
+			assert.Equal(t, tests.expectOut, v.String(), tests.tag)
+		}
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a         string
+		b         string
+		expectOut int
+	}{
+		{a: "1.0.0", b: "1.0.0", expectOut: 0},
+		{a: "1.0.1", b: "1.0.0", expectOut: 1},
+		{a: "1.9.0", b: "1.10.0", expectOut: -1},
+		{a: "1.0.0-rc.1", b: "1.0.0", expectOut: -1},
+		{a: "1.0.0-alpha", b: "1.0.0-alpha.1", expectOut: -1},
+		{a: "1.0.0-rc.2", b: "1.0.0-rc.10", expectOut: -1},
+		{a: "1.0.0-beta", b: "1.0.0-2", expectOut: 1},
+	}
+
+	for _, tests := range tests {
+		a, _ := parseVersion(tests.a, "")
+		b, _ := parseVersion(tests.b, "")
+		assert.Equal(t, tests.expectOut, a.compare(b), "%v compared to %v", tests.a, tests.b)
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		expectOut  bool
+	}{
+		{constraint: ">=1.4.0, <2.0.0", version: "v1.4.0", expectOut: true},
+		{constraint: ">=1.4.0, <2.0.0", version: "v1.9.9", expectOut: true},
+		{constraint: ">=1.4.0, <2.0.0", version: "v2.0.0", expectOut: false},
+		{constraint: ">= 1.4.0 < 2.0.0", version: "v1.3.9", expectOut: false},
+		{constraint: ">=1.4.0, <2.0.0", version: "v1.5.0-rc.1", expectOut: false},
+		{constraint: "~1.4", version: "1.4.7", expectOut: true},
+		{constraint: "~1.4", version: "1.5.0", expectOut: false},
+		{constraint: "^1.4.2", version: "1.9.0", expectOut: true},
+		{constraint: "^1.4.2", version: "2.0.0", expectOut: false},
+		{constraint: "^0.4.2", version: "0.5.0", expectOut: false},
+		{constraint: "1.4.x", version: "1.4.3", expectOut: true},
+		{constraint: "1.4", version: "1.5.0", expectOut: false},
+		{constraint: "1.4.2", version: "1.4.2", expectOut: true},
+		{constraint: "!=1.4.2", version: "1.4.2", expectOut: false},
+		{constraint: "^1.0.0 || ^3.0.0", version: "3.1.0", expectOut: true},
+		{constraint: "^1.0.0 || ^3.0.0", version: "2.1.0", expectOut: false},
+		{constraint: ">=2.0.0-rc.1", version: "2.0.0-rc.2", expectOut: true},
+		{constraint: "*", version: "0.0.1", expectOut: true},
+		{constraint: ">1.4", version: "1.4.5", expectOut: false},
+		{constraint: ">1.4", version: "1.5.0", expectOut: true},
+		{constraint: ">1", version: "1.9.0", expectOut: false},
+		{constraint: ">1", version: "2.0.0", expectOut: true},
+		{constraint: "<=1.4", version: "1.4.5", expectOut: true},
+		{constraint: "<=1.4", version: "1.5.0", expectOut: false},
+		{constraint: "<1.4", version: "1.3.9", expectOut: true},
+		{constraint: "<1.4", version: "1.4.0", expectOut: false},
+		{constraint: ">=1.4", version: "1.4.0", expectOut: true},
+		{constraint: "!=1.4", version: "1.4.5", expectOut: false},
+		{constraint: "!=1.4", version: "1.3.9", expectOut: true},
+		{constraint: "!=1.4", version: "1.5.0", expectOut: true},
+		{constraint: "=1.4", version: "1.4.5", expectOut: true},
+		{constraint: "=1.4", version: "1.5.0", expectOut: false},
+		{constraint: ">1.4.x", version: "1.4.9", expectOut: false},
+	}
+
+	for _, tests := range tests {
+		c, err := parseConstraint(tests.constraint)
+		if err != nil {
+			t.Errorf("Unable to parse constraint %v, err: %v", tests.constraint, err)
+			continue
+		}
+		v, _ := parseVersion(tests.version, "")
+		assert.Equal(t, tests.expectOut, c.check(v), "%v with version %v", tests.constraint, tests.version)
+	}
+
+	for _, invalid := range []string{"", ">=", "foo", "1.x-rc.1", ">=1.0.0,, ||"} {
+		_, err := parseConstraint(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
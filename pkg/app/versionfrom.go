@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v33/github"
+)
+
+// discoverVersion finds the version of a nonGithubURL bin from the versionFrom source.
+// github uses the same rules as a GitHub bin, so version, tagPrefix, tagPattern, channel and excludeTags can be used.
+// url without regex returns the trimmed page, with regex the highest version matching the version constraint is used, or the first match if no match is a version.
+func discoverVersion(ctx context.Context, client *github.Client, httpClient *http.Client, binConfig config.Bin) (string, error) {
+	log := logr.FromContext(ctx)
+	from := binConfig.VersionFrom
+
+	switch {
+	case from.Github != "" && from.URL != "":
+		return "", fmt.Errorf("%v: versionFrom can only have one of github and url", binConfig.Cli)
+	case from.Github != "":
+		parts := strings.Split(from.Github, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return "", fmt.Errorf("%v: invalid versionFrom github %q, it should look like owner/repo", binConfig.Cli, from.Github)
+		}
+		source := binConfig
+		source.Owner, source.Repo, source.NonGithubURL = parts[0], parts[1], ""
+		release, err := resolveRelease(ctx, client, source)
+		if err != nil {
+			return "", err
+		}
+		log.V(1).Info("Discovered version", "cli", binConfig.Cli, "github", from.Github, "version", release.GetTagName())
+		return release.GetTagName(), nil
+	case from.URL == "":
+		return "", fmt.Errorf("%v: versionFrom needs github or url", binConfig.Cli)
+	}
+
+	body, _, err := fetchURL(ctx, httpClient, binConfig.Cli, from.URL)
+	if err != nil {
+		return "", err
+	}
+
+	version := strings.TrimSpace(string(body))
+	if from.Regex != "" {
+		version, err = versionFromPage(binConfig, string(body))
+		if err != nil {
+			return "", err
+		}
+	}
+	if version == "" || strings.ContainsAny(version, " \t\r\n") {
+		return "", fmt.Errorf("%v: unable to find a version in %v", binConfig.Cli, from.URL)
+	}
+	log.V(1).Info("Discovered version", "cli", binConfig.Cli, "url", from.URL, "version", version)
+	return version, nil
+}
+
+// versionFromPage returns the highest version on the page matching the regex and the version constraint
+func versionFromPage(binConfig config.Bin, page string) (string, error) {
+	r, err := regexp.Compile(binConfig.VersionFrom.Regex)
+	if err != nil {
+		return "", fmt.Errorf("%v: invalid versionFrom regex %q: %v", binConfig.Cli, binConfig.VersionFrom.Regex, err)
+	}
+	versionConstraint, err := parseConstraint("*")
+	if binConfig.Version != "" {
+		versionConstraint, err = parseConstraint(binConfig.Version)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var first, best string
+	var bestVersion semver
+	for _, match := range r.FindAllString(page, -1) {
+		found, _ := extractTagVersion(match, r)
+		if first == "" {
+			first = found
+		}
+		v, ok := parseVersion(found, binConfig.TagPrefix)
+		if !ok || !versionConstraint.check(v) {
+			continue
+		}
+		if best == "" || v.compare(bestVersion) > 0 {
+			best = found
+			bestVersion = v
+		}
+	}
+	if best != "" {
+		return best, nil
+	}
+	if binConfig.Version == "" && first != "" {
+		return first, nil
+	}
+	return "", fmt.Errorf("%v: no match of versionFrom regex %q on %v matching version %q", binConfig.Cli, binConfig.VersionFrom.Regex, binConfig.VersionFrom.URL, binConfig.Version)
+}
+
+// renderVersionURL renders {{.Version}} and {{.VersionNumber}}, the version without a leading v, in nonGithubURL and checksumAsset
+func renderVersionURL(cliName, text, version string) (string, error) {
+	rendered, err := renderTemplate(text, templateData{Version: version, VersionNumber: strings.TrimPrefix(version, "v")})
+	if err != nil {
+		return "", fmt.Errorf("%v: %v", cliName, err)
+	}
+	return rendered, nil
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/NissesSenap/gitHubBinDl/pkg/config"
+	"github.com/go-logr/logr"
+	logrTesting "github.com/go-logr/logr/testing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscoverVersion(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/release/stable.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "v1.20.2\n")
+	})
+	mux.HandleFunc("/terraform/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/terraform/0.14.5/">terraform_0.14.5</a><a href="/terraform/0.15.0-beta1/">terraform_0.15.0-beta1</a><a href="/terraform/0.13.6/">terraform_0.13.6</a>`)
+	})
+	mux.HandleFunc("/repos/helm/helm/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v3.5.0"}`)
+	})
+	mux.HandleFunc("/repos/helm/helm/releases", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"tag_name": "v3.5.0"}, {"tag_name": "v3.4.2"}]`)
+	})
+	client, server := newTestGitHubClient(t, mux)
+	defer server.Close()
+
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	viper.Set(config.DefaultHTTPtimeoutkey, 5)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024*1024)
+
+	tests := []struct {
+		versionFrom config.VersionFrom
+		version     string
+		expectOut   string
+		expectErr   bool
+	}{
+		{versionFrom: config.VersionFrom{Github: "helm/helm"}, expectOut: "v3.5.0"},
+		{versionFrom: config.VersionFrom{Github: "helm/helm"}, version: "<3.5.0", expectOut: "v3.4.2"},
+		{versionFrom: config.VersionFrom{URL: server.URL + "/release/stable.txt"}, expectOut: "v1.20.2"},
+		{versionFrom: config.VersionFrom{URL: server.URL + "/terraform/", Regex: `terraform_([\d.]+[\w-]*)`}, expectOut: "0.14.5"},
+		{versionFrom: config.VersionFrom{URL: server.URL + "/terraform/", Regex: `terraform_([\d.]+[\w-]*)`}, version: "~0.13", expectOut: "0.13.6"},
+		{versionFrom: config.VersionFrom{URL: server.URL + "/terraform/", Regex: `terraform_([\d.]+[\w-]*)`}, version: ">=1.0.0", expectErr: true},
+		{versionFrom: config.VersionFrom{URL: server.URL + "/terraform/"}, expectErr: true},
+		{versionFrom: config.VersionFrom{URL: server.URL + "/missing.txt"}, expectErr: true},
+		{versionFrom: config.VersionFrom{Github: "helm"}, expectErr: true},
+		{versionFrom: config.VersionFrom{Github: "helm/helm", URL: server.URL + "/release/stable.txt"}, expectErr: true},
+		{versionFrom: config.VersionFrom{}, expectErr: true},
+	}
+
+	for _, tests := range tests {
+		versionFrom := tests.versionFrom
+		version, err := discoverVersion(ctx, client, server.Client(), config.Bin{Cli: "tool", Version: tests.version, VersionFrom: &versionFrom})
+		if tests.expectErr {
+			assert.Error(t, err, "%+v", tests.versionFrom)
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unable to discover version for %+v, err: %v", tests.versionFrom, err)
+			continue
+		}
+		assert.Equal(t, tests.expectOut, version, "%+v", tests.versionFrom)
+	}
+}
+
+func TestResolveAssetVersionFrom(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/release/stable.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "v1.20.2")
+	})
+	client, server := newTestGitHubClient(t, mux)
+	defer server.Close()
+
+	ctx := logr.NewContext(context.Background(), logrTesting.NullLogger{})
+	defer viper.Reset()
+	viper.Set(config.DefaultHTTPtimeoutkey, 5)
+	viper.Set(config.DefaultMaxDownloadSizeKey, 1024*1024)
+
+	bin := config.Bin{
+		Cli:          "kubectl{{.Ext}}",
+		NonGithubURL: "https://dl.k8s.io/release/{{.Version}}/bin/{{.OS}}/{{.Arch}}/kubectl{{.Ext}}?n={{.VersionNumber}}",
+		VersionFrom:  &config.VersionFrom{URL: server.URL + "/release/stable.txt"},
+	}
+	rendered, err := renderBin(bin, platform{os: "windows", arch: "amd64"})
+	if err != nil {
+		t.Fatalf("Unable to render bin %v", err)
+	}
+	entry, err := resolveAsset(ctx, client, server.Client(), rendered, platform{os: "windows", arch: "amd64"})
+	if err != nil {
+		t.Fatalf("Unable to resolve asset %v", err)
+	}
+	assert.Equal(t, "https://dl.k8s.io/release/v1.20.2/bin/windows/amd64/kubectl.exe?n=1.20.2", entry.DownloadURL)
+	assert.Equal(t, "v1.20.2", entry.Tag)
+	assert.Equal(t, "kubectl.exe", entry.AssetName)
+
+	// {{.Version}} needs versionFrom and can only be used in nonGithubURL
+	_, err = renderBin(config.Bin{Cli: "kubectl", NonGithubURL: bin.NonGithubURL}, hostPlatform())
+	assert.Error(t, err)
+	_, err = renderBin(config.Bin{Cli: "kubectl-{{.Version}}", NonGithubURL: bin.NonGithubURL, VersionFrom: bin.VersionFrom}, hostPlatform())
+	assert.Error(t, err)
+}
@@ -6,40 +6,218 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/NissesSenap/gitHubBinDl/build"
 	"github.com/NissesSenap/gitHubBinDl/pkg/util"
 	"github.com/go-logr/logr"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 // Bin a representation on what to download
 type Bin struct {
-	Cli                string   `yaml:"cli"`
-	Owner              string   `yaml:"owner"`
-	Repo               string   `yaml:"repo"`
-	Tag                string   `yaml:"tag"`
-	Match              string   `yaml:"match"`
-	Download           bool     `yaml:"download"`
-	NonGithubURL       string   `yaml:"nonGithubURL"`
-	Backup             bool     `yaml:"backup"`
-	CompletionLocation string   `yaml:"completionLocation"`
-	CompletionArgs     []string `yaml:"completionArgs"`
+	Cli                string              `yaml:"cli"`
+	Owner              string              `yaml:"owner"`
+	Repo               string              `yaml:"repo"`
+	Tag                string              `yaml:"tag"`
+	Version            string              `yaml:"version"`
+	TagPrefix          string              `yaml:"tagPrefix"`
+	TagPattern         string              `yaml:"tagPattern"`
+	Channel            string              `yaml:"channel"`
+	ExcludeTags        []string            `yaml:"excludeTags"`
+	Match              string              `yaml:"match"`
+	Exclude            []string            `yaml:"exclude"`
+	Download           *bool               `yaml:"download"`
+	NonGithubURL       string              `yaml:"nonGithubURL"`
+	ChecksumAsset      string              `yaml:"checksumAsset"`
+	RequireChecksum    *bool               `yaml:"requireChecksum"`
+	SHA256             Digest              `yaml:"sha256"`
+	BinarySHA256       Digest              `yaml:"binarySha256"`
+	Verify             *Verify             `yaml:"verify"`
+	VersionFrom        *VersionFrom        `yaml:"versionFrom"`
+	Backup             bool                `yaml:"backup"`
+	BackupRetention    *Retention          `yaml:"backupRetention"`
+	CompletionLocation string              `yaml:"completionLocation"`
+	CompletionArgs     []string            `yaml:"completionArgs"`
+	Files              []File              `yaml:"files"`
+	Format             string              `yaml:"format"`
+	Layout             []LayoutRule        `yaml:"layout"`
+	ArchivePath        string              `yaml:"archivePath"`
+	InstallAs          string              `yaml:"installAs"`
+	OSAliases          map[string][]string `yaml:"osAliases"`
+	ArchAliases        map[string][]string `yaml:"archAliases"`
+	Platforms          []string            `yaml:"platforms"`
+}
+
+// File a extra file to install from the archive, the archive member is selected with path, a exact path or glob, or the match regex
+type File struct {
+	Path  string `yaml:"path"`
+	Match string `yaml:"match"`
+	Name  string `yaml:"name"`
+	Mode  string `yaml:"mode"`
+}
+
+// VersionFrom where the version used in a nonGithubURL template is found.
+// github is the latest release of a owner/repo, url is a page that contains the version as plain text or a page that regex is used on.
+type VersionFrom struct {
+	Github string `yaml:"github"`
+	URL    string `yaml:"url"`
+	Regex  string `yaml:"regex"`
+}
+
+// Verify signatures that have to be valid before a download is installed
+type Verify struct {
+	Cosign     *Cosign     `yaml:"cosign"`
+	GPG        *GPG        `yaml:"gpg"`
+	Minisign   *Minisign   `yaml:"minisign"`
+	Provenance *Provenance `yaml:"provenance"`
+}
+
+// Cosign verifies a blob signed by cosign, with a public key or keyless with a certificate issued to identity by issuer.
+// Signature, certificate and bundle are regex picking the files in the release, by default they are found next to the asset.
+type Cosign struct {
+	Key            string `yaml:"key"`
+	Identity       string `yaml:"identity"`
+	IdentityRegexp string `yaml:"identityRegexp"`
+	Issuer         string `yaml:"issuer"`
+	TrustRoot      string `yaml:"trustRoot"`
+	Signature      string `yaml:"signature"`
+	Certificate    string `yaml:"certificate"`
+	Bundle         string `yaml:"bundle"`
+}
+
+// GPG verifies a detached gpg signature of the checksum file, or of the asset if there is no checksum file.
+// Key is a file with one or more armored public keys and signature a regex picking the signature in the release.
+type GPG struct {
+	Key       string `yaml:"key"`
+	Signature string `yaml:"signature"`
+}
+
+// Minisign verifies a minisign signature of the checksum file, or of the asset if there is no checksum file.
+// PublicKey is the base64 public key like minisign -G prints it.
+type Minisign struct {
+	PublicKey string `yaml:"publicKey"`
+	Signature string `yaml:"signature"`
+}
+
+// Provenance verifies a SLSA provenance attestation of the asset, signed with key or keyless by the builder with a certificate from trustRoot.
+// sourceRepo defaults to github.com/owner/repo and attestation is a regex picking the attestation in the release.
+type Provenance struct {
+	Key         string `yaml:"key"`
+	TrustRoot   string `yaml:"trustRoot"`
+	Issuer      string `yaml:"issuer"`
+	BuilderID   string `yaml:"builderID"`
+	SourceRepo  string `yaml:"sourceRepo"`
+	Attestation string `yaml:"attestation"`
+}
+
+// Layout installs more than the cli from archives, binaries in prefix/bin and man pages, completions, licenses and docs in prefix/share
+type Layout struct {
+	Prefix string `yaml:"prefix"`
+}
+
+// LayoutRule installs archive members matching the regex in dir, relative to the installLayout prefix
+type LayoutRule struct {
+	Match string `yaml:"match"`
+	Dir   string `yaml:"dir"`
+	Name  string `yaml:"name"`
+	Mode  string `yaml:"mode"`
+}
+
+// DownloadEnabled returns false if download is set to false, the bin should then only be reported
+func (b Bin) DownloadEnabled() bool {
+	return b.Download == nil || *b.Download
+}
+
+// Retention how many backups to keep and for how long, everything is kept if nothing is set
+type Retention struct {
+	Keep   int    `yaml:"keep"`
+	MaxAge string `yaml:"maxAge"`
+}
+
+// MaxAgeDuration parses MaxAge, on top of what time.ParseDuration supports days can be used, example: 30d
+func (r Retention) MaxAgeDuration() (time.Duration, error) {
+	if r.MaxAge == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(r.MaxAge, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(r.MaxAge, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid maxAge %v: %v", r.MaxAge, err)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(r.MaxAge)
+}
+
+// ChecksumRequired returns true if the bin can't be installed without verifying the checksum
+func (b Bin) ChecksumRequired() bool {
+	return b.RequireChecksum != nil && *b.RequireChecksum
+}
+
+// DigestAnyPlatform is the key used for a digest that is set as a single value instead of per platform
+const DigestAnyPlatform = "*"
+
+// Digest a pinned sha256, either a single value for every platform or a map with one value per platform like linux/amd64
+type Digest map[string]string
+
+// For returns the digest for the platform, the value for every platform is used if the platform is missing
+func (d Digest) For(platform string) string {
+	if digest, ok := d[platform]; ok {
+		return strings.ToLower(digest)
+	}
+	return strings.ToLower(d[DigestAnyPlatform])
+}
+
+// digestHook lets a Digest be written as a single string in the config file
+func digestHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(Digest{}) || from.Kind() != reflect.String {
+		return data, nil
+	}
+	return Digest{DigestAnyPlatform: data.(string)}, nil
+}
+
+// unmarshalConfig unmarshals the viper config in to item.
+// The default viper hooks is replaced by DecodeHook so they are added again next to digestHook.
+func unmarshalConfig(item *Items) error {
+	return viper.Unmarshal(item, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		digestHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)))
+}
+
+// InstallName returns the name the cli is installed as, installAs if set else cli
+func (b Bin) InstallName() string {
+	if b.InstallAs != "" {
+		return b.InstallAs
+	}
+	return b.Cli
 }
 
 // Items config file struct
 type Items struct {
-	Bins                []Bin    `yaml:"bins"`
-	GitHubAPIkey        string   `yaml:"githubAPIkey"`
-	HTTPtimeout         int      `yaml:"httpTimeout"`
-	HTTPinsecure        bool     `yaml:"httpInsecure"`
-	SaveLocation        string   `yaml:"saveLocation"`
-	BaseURL             string   `yaml:"baseURL"`
-	UploadURL           string   `yaml:"uploadURL"`
-	MaxFileSize         int64    `yaml:"maxFileSize"`
-	NotOkCompletionArgs []string `yaml:"notOkCompletionArgs"`
+	Bins                []Bin     `yaml:"bins"`
+	GitHubAPIkey        string    `yaml:"githubAPIkey"`
+	HTTPtimeout         int       `yaml:"httpTimeout"`
+	HTTPinsecure        bool      `yaml:"httpInsecure"`
+	SaveLocation        string    `yaml:"saveLocation"`
+	Platform            string    `yaml:"platform"`
+	Platforms           []string  `yaml:"platforms"`
+	BackupLocation      string    `yaml:"backupLocation"`
+	BackupRetention     Retention `yaml:"backupRetention"`
+	InstallLayout       Layout    `yaml:"installLayout"`
+	RequireChecksum     bool      `yaml:"requireChecksum"`
+	BaseURL             string    `yaml:"baseURL"`
+	UploadURL           string    `yaml:"uploadURL"`
+	MaxFileSize         int64     `yaml:"maxFileSize"`
+	MaxDownloadSize     int64     `yaml:"maxDownloadSize"`
+	NotOkCompletionArgs []string  `yaml:"notOkCompletionArgs"`
 }
 
 // default Keys & values for global values lik saveLocation & HttpTimeout, notice that only the keys are Global
@@ -59,14 +237,49 @@ const (
 	DefaultSaveLocationKey = "saveLocation"
 	// defaultSaveLocationValue is defined in ManageConfig()
 
+	// DefaultBackupLocationKey saveLocation is used if not set
+	DefaultBackupLocationKey = "backupLocation"
+
 	DefaultBaseURLKey  = "baseURL"
 	DefaultUploadRLKey = "uploadURL"
 
 	DefaultMaxFileSizeKey   = "maxFileSize"
 	defaultMaxFileSizeValue = int64(104857600) //1024*1024*100 aka 100 Mb
 
+	DefaultMaxDownloadSizeKey   = "maxDownloadSize"
+	defaultMaxDownloadSizeValue = int64(1073741824) //1024*1024*1024 aka 1 Gb
+
 	DefaultNotOkCompletionArgsKey = "notOkCompletionArgs"
 	//defaultNotOkCompletionArgsValue is defined in ManageConfig()
+
+	// DefaultInstallLayoutPrefixKey installLayout is only used if the prefix is set
+	DefaultInstallLayoutPrefixKey = "installLayout.prefix"
+
+	// DefaultPlatformKey the platform to download for, example: linux/amd64. The host platform is used if not set
+	DefaultPlatformKey = "platform"
+
+	DefaultFrozenKey   = "frozen"
+	defaultFrozenValue = false
+
+	DefaultOutputKey   = "output"
+	defaultOutputValue = "table"
+
+	DefaultRollbackToKey = "to"
+
+	// DefaultExplainKey prints the scores of the assets when match is empty
+	DefaultExplainKey = "explain"
+
+	// DefaultCommandKey and DefaultRollbackCliKey is set from the arguments and not from the config file
+	DefaultCommandKey     = "command"
+	DefaultRollbackCliKey = "rollbackCli"
+)
+
+// Commands that can be given as the first argument, install is used if no command is given
+const (
+	CommandInstall  = "install"
+	CommandUpdate   = "update"
+	CommandCheck    = "check"
+	CommandRollback = "rollback"
 )
 
 // ManageConfig read all the user input and returns Items
@@ -94,7 +307,10 @@ func ManageConfig(ctx context.Context) (Items, error) {
 	viper.SetDefault(DefaultHTTPtimeoutkey, defaultHTTPtimeoutValue)
 	viper.SetDefault(DefaultHTTPinsecureKey, defaultHTTPinsecureValue)
 	viper.SetDefault(DefaultSaveLocationKey, defaultSaveLocationValue)
+	viper.SetDefault(DefaultBackupLocationKey, "")
+	viper.SetDefault(DefaultPlatformKey, "")
 	viper.SetDefault(DefaultMaxFileSizeKey, defaultMaxFileSizeValue)
+	viper.SetDefault(DefaultMaxDownloadSizeKey, defaultMaxDownloadSizeValue)
 	viper.SetDefault(DefaultNotOkCompletionArgsKey, defaultNotOkCompletionArgsValue)
 	viper.SetDefault(DefaultBaseURLKey, "")
 	viper.SetDefault(DefaultUploadRLKey, "")
@@ -118,7 +334,7 @@ func ManageConfig(ctx context.Context) (Items, error) {
 	if err != nil {
 		return item, err
 	}
-	err = viper.Unmarshal(&item)
+	err = unmarshalConfig(&item)
 	if err != nil {
 		return item, err
 	}
@@ -138,6 +354,11 @@ func readCli() error {
 	help := pflag.BoolP("help", "h", false, "prints the help output.")
 	_ = pflag.StringP(DefaultConfigFileKey, "c", "", "Configfile to read data from, default data.yaml")
 	version := pflag.BoolP("version", "v", false, "print application version.")
+	_ = pflag.Bool(DefaultFrozenKey, defaultFrozenValue, "Install exactly what githubbindl.lock contains and fail if anything differs.")
+	_ = pflag.String(DefaultPlatformKey, "", "The platform to download for, example: linux/amd64. Default is the platform githubbindl runs on.")
+	_ = pflag.String(DefaultOutputKey, defaultOutputValue, "Report output format, table or json.")
+	_ = pflag.String(DefaultRollbackToKey, "", "The backup to restore with rollback, example: 2021-01-10. The newest backup is used if not set.")
+	_ = pflag.Bool(DefaultExplainKey, false, "Print how the assets was scored for bins without match.")
 	//pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 	pflag.Parse()
 	err := viper.BindPFlags(pflag.CommandLine)
@@ -146,6 +367,11 @@ func readCli() error {
 	}
 
 	if *help {
+		fmt.Printf("Usage: githubbindl [command] [flags]\n\nCommands:\n")
+		fmt.Printf("  %v\tdownload and install all bins, the default\n", CommandInstall)
+		fmt.Printf("  %v\trefresh githubbindl.lock with the latest matching releases without installing\n", CommandUpdate)
+		fmt.Printf("  %v\treport installed and available versions without downloading anything\n", CommandCheck)
+		fmt.Printf("  %v <cli>\tlist the backups of cli and restore the newest or the one given with --to\n\nFlags:\n", CommandRollback)
 		pflag.PrintDefaults()
 		os.Exit(0)
 	}
@@ -154,6 +380,26 @@ func readCli() error {
 		fmt.Printf("githubBinDl Version: %s, BuildDate: %s", build.Version, build.BuildDate)
 		os.Exit(0)
 	}
+
+	command := CommandInstall
+	if pflag.NArg() > 0 {
+		command = pflag.Arg(0)
+	}
+	switch command {
+	case CommandInstall, CommandUpdate, CommandCheck:
+	case CommandRollback:
+		if pflag.NArg() != 2 {
+			return fmt.Errorf("%v requires the name of one cli, example: githubbindl %v tkn", CommandRollback, CommandRollback)
+		}
+		viper.Set(DefaultRollbackCliKey, pflag.Arg(1))
+	default:
+		return fmt.Errorf("unknown command %v", command)
+	}
+	viper.Set(DefaultCommandKey, command)
+
+	if command != CommandInstall && viper.GetBool(DefaultFrozenKey) {
+		return fmt.Errorf("--frozen can't be used together with %v", command)
+	}
 	return nil
 }
 
@@ -3,12 +3,14 @@ package config
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/go-logr/logr"
 	logrTesting "github.com/go-logr/logr/testing"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestManageConfig(t *testing.T) {
@@ -77,3 +79,32 @@ func TestFuGetEnv(t *testing.T) {
 		}
 	}
 }
+
+func TestDigest(t *testing.T) {
+	defer viper.Reset()
+	viper.SetConfigType("yaml")
+	config := `
+bins:
+  - cli: tkn
+    sha256: ABC123
+  - cli: helm
+    sha256:
+      linux/amd64: abc
+      "*": def
+    binarySha256: fed
+`
+	if err := viper.ReadConfig(strings.NewReader(config)); err != nil {
+		t.Fatalf("Unable to read config %v", err)
+	}
+	var item Items
+	if err := unmarshalConfig(&item); err != nil {
+		t.Fatalf("Unable to unmarshal config %v", err)
+	}
+
+	assert.Equal(t, "abc123", item.Bins[0].SHA256.For("linux/amd64"))
+	assert.Equal(t, "abc123", item.Bins[0].SHA256.For("darwin/arm64"))
+	assert.Equal(t, "", item.Bins[0].BinarySHA256.For("linux/amd64"))
+	assert.Equal(t, "abc", item.Bins[1].SHA256.For("linux/amd64"))
+	assert.Equal(t, "def", item.Bins[1].SHA256.For("windows/amd64"))
+	assert.Equal(t, "fed", item.Bins[1].BinarySHA256.For("linux/amd64"))
+}
@@ -1,13 +1,36 @@
 package util
 
-import "os"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
 
 const DateFormat = "2006-01-02"
 
+// DateTimeFormat is used in file names so it can't contain :
+const DateTimeFormat = "2006-01-02_150405"
+
 // MakeDirectoryIfNotExists create a folder if it's missing
 func MakeDirectoryIfNotExists(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return os.Mkdir(path, os.ModeDir|0755)
+		return os.MkdirAll(path, os.ModeDir|0755)
 	}
 	return nil
 }
+
+// FileSHA256 returns the hex encoded sha256 of a file
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}